@@ -0,0 +1,93 @@
+package httprs
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// minimal transfer measured to estimate the throughput
+	throughputSampleBytes = 64 * 1024
+	// weight of new measures
+	estimatorAlpha = 0.3
+)
+
+// A seekEstimator measures the latency of range requests and the throughput of response
+// bodies, to estimate how many bytes can be discarded in the time a new request takes.
+type seekEstimator struct {
+	min, max int64
+
+	mu         sync.Mutex // protects the fields below
+	latency    time.Duration
+	throughput float64 // bytes per second
+}
+
+func ewma(old, v float64) float64 {
+	if old == 0 {
+		return v
+	}
+	return old + estimatorAlpha*(v-old)
+}
+
+func (e *seekEstimator) observeLatency(d time.Duration) {
+	e.mu.Lock()
+	e.latency = time.Duration(ewma(float64(e.latency), float64(d)))
+	e.mu.Unlock()
+}
+
+func (e *seekEstimator) observeTransfer(n int64, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	e.mu.Lock()
+	e.throughput = ewma(e.throughput, float64(n)/d.Seconds())
+	e.mu.Unlock()
+}
+
+// threshold returns the bandwidth-delay product, within [min, max], or def before any measure
+func (e *seekEstimator) threshold(def int64) int64 {
+	e.mu.Lock()
+	latency, throughput := e.latency, e.throughput
+	e.mu.Unlock()
+	t := def
+	if latency > 0 && throughput > 0 {
+		t = int64(latency.Seconds() * throughput)
+	}
+	if t < e.min {
+		t = e.min
+	}
+	if t > e.max {
+		t = e.max
+	}
+	return t
+}
+
+// meteredBody measures the time spent reading a body
+type meteredBody struct {
+	io.ReadCloser
+	e *seekEstimator
+	n int64
+	d time.Duration
+}
+
+func (m *meteredBody) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := m.ReadCloser.Read(p)
+	m.d += time.Since(start)
+	m.n += int64(n)
+	if m.n >= throughputSampleBytes {
+		m.e.observeTransfer(m.n, m.d)
+		m.n, m.d = 0, 0
+	}
+	return n, err
+}
+
+// ShortSeekBytes returns how many bytes a forward Seek may currently discard instead of
+// doing a new range request.
+func (r *HttpReadSeeker) ShortSeekBytes() int64 {
+	if r.estimator != nil && !r.shortSeekSet {
+		return r.estimator.threshold(r.shortSeek)
+	}
+	return r.shortSeek
+}
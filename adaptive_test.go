@@ -0,0 +1,64 @@
+package httprs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAdaptiveShortSeek(t *testing.T) {
+	Convey("Scenario: testing the adaptive short seek threshold", t, func() {
+		Convey("The threshold is the bandwidth-delay product", func() {
+			e := &seekEstimator{min: 1024, max: 1024 * 1024}
+			So(e.threshold(shortSeekBytes), ShouldEqual, 1024)
+			e.observeLatency(100 * time.Millisecond)
+			e.observeTransfer(1000*1000, time.Second)
+			So(e.threshold(shortSeekBytes), ShouldEqual, 100*1000)
+			e.observeTransfer(100*1000*1000, time.Second)
+			So(e.threshold(shortSeekBytes), ShouldEqual, 1024*1024)
+
+			e = &seekEstimator{min: 1024, max: 1024 * 1024}
+			e.observeLatency(time.Millisecond)
+			e.observeTransfer(1000, time.Second)
+			So(e.threshold(shortSeekBytes), ShouldEqual, 1024)
+		})
+
+		Convey("WithShortSeekBytes overrides the adaptive threshold", func() {
+			r, err := NewHttpReadSeekerWithOptions(newTestResponse(nil), WithShortSeekBytes(10), WithAdaptiveShortSeek(100, 1000))
+			So(err, ShouldBeNil)
+			So(r.ShortSeekBytes(), ShouldEqual, 10)
+			r, err = NewHttpReadSeekerWithOptions(newTestResponse(nil), WithAdaptiveShortSeek(100, 1000))
+			So(err, ShouldBeNil)
+			So(r.ShortSeekBytes(), ShouldEqual, 1000)
+		})
+
+		Convey("Long seeks are cheaper than requests on slow to answer servers", func() {
+			content := make([]byte, 1024*1024)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.Method == "GET" {
+					time.Sleep(20 * time.Millisecond)
+				}
+				http.ServeContent(w, req, "file", time.Time{}, bytes.NewReader(content))
+			}))
+			defer server.Close()
+			r, err := Open(context.Background(), server.URL, WithAdaptiveShortSeek(1024, 512*1024))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			_, err = io.CopyN(ioutil.Discard, struct{ io.Reader }{r}, 256*1024)
+			So(err, ShouldBeNil)
+			So(r.ShortSeekBytes(), ShouldBeGreaterThan, 100*1024)
+			_, err = r.Seek(100*1024, io.SeekCurrent)
+			So(err, ShouldBeNil)
+			_, err = r.Read(make([]byte, 4))
+			So(err, ShouldBeNil)
+			So(r.Stats().RangeRequests, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,92 @@
+package httprs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync/atomic"
+)
+
+// A BlockKey identifies a block of remote content.
+type BlockKey struct {
+	// URL of the remote content
+	URL string
+	// Validator is the ETag or Last-Modified value the block was fetched with
+	Validator string
+	// Index is the position of the block, in block sizes
+	Index int64
+}
+
+// A Cache stores fixed size blocks of remote content. Only the last block of a file
+// may be shorter than BlockSize.
+//
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	BlockSize() int64
+	Get(key BlockKey) ([]byte, bool)
+	Put(key BlockKey, block []byte)
+}
+
+// An Invalidator is a Cache that can remove all the blocks of a URL. HttpReadSeeker
+// calls Invalidate when it detects that the remote content has changed.
+type Invalidator interface {
+	Invalidate(url string)
+}
+
+// readBlocks fills p from the cache, starting at off, fetching missing blocks.
+func (r *HttpReadSeeker) readBlocks(ctx context.Context, p []byte, off int64) (n int, err error) {
+	bs := r.cache.BlockSize()
+	for n < len(p) {
+		if size, ok := r.knownSize(); ok && off >= size {
+			return n, io.EOF
+		}
+		idx := off / bs
+		block, cached, err := r.block(ctx, idx)
+		if errors.Is(err, ErrInvalidRange) && n > 0 {
+			return n, io.EOF
+		}
+		if err != nil {
+			return n, err
+		}
+		from := off - idx*bs
+		if from >= int64(len(block)) {
+			return n, io.EOF
+		}
+		nn := copy(p[n:], block[from:])
+		if cached {
+			atomic.AddInt64(&r.stats.bytesFromCache, int64(nn))
+		}
+		n += nn
+		off += int64(nn)
+		if int64(len(block)) < bs && off >= idx*bs+int64(len(block)) && n < len(p) {
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+// block returns the block at idx, from the cache (cached is then true) or from a range request.
+func (r *HttpReadSeeker) block(ctx context.Context, idx int64) (b []byte, cached bool, err error) {
+	bs := r.cache.BlockSize()
+	key := BlockKey{URL: r.req.URL.String(), Validator: r.validator(), Index: idx}
+	if b, ok := r.cache.Get(key); ok {
+		return b, true, nil
+	}
+	body, err := r.fetch(ctx, idx*bs, bs)
+	if errors.Is(err, ErrContentHasChanged) {
+		if i, ok := r.cache.(Invalidator); ok {
+			i.Invalidate(key.URL)
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer body.Close()
+	b, err = ioutil.ReadAll(io.LimitReader(body, bs))
+	if err != nil {
+		return nil, false, err
+	}
+	r.cache.Put(key, b)
+	return b, false, nil
+}
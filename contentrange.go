@@ -0,0 +1,48 @@
+package httprs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseContentRange parses a "bytes first-last/total" Content-Range header value.
+// total is -1 when the server sent "*", first and last are -1 for "bytes */total".
+func parseContentRange(s string) (first, last, total int64, err error) {
+	invalid := fmt.Errorf("Invalid Content-Range %q", s)
+	if !strings.HasPrefix(s, "bytes ") {
+		return 0, 0, 0, invalid
+	}
+	s = strings.TrimSpace(s[len("bytes "):])
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return 0, 0, 0, invalid
+	}
+	rng, size := s[:i], s[i+1:]
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil || total < 0 {
+			return 0, 0, 0, invalid
+		}
+	}
+	if rng == "*" {
+		if total < 0 {
+			return 0, 0, 0, invalid
+		}
+		return -1, -1, total, nil
+	}
+	j := strings.IndexByte(rng, '-')
+	if j < 0 {
+		return 0, 0, 0, invalid
+	}
+	if first, err = strconv.ParseInt(rng[:j], 10, 64); err != nil || first < 0 {
+		return 0, 0, 0, invalid
+	}
+	if last, err = strconv.ParseInt(rng[j+1:], 10, 64); err != nil || last < first {
+		return 0, 0, 0, invalid
+	}
+	if total >= 0 && last >= total {
+		return 0, 0, 0, invalid
+	}
+	return first, last, total, nil
+}
@@ -0,0 +1,101 @@
+package httprs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseContentRange(t *testing.T) {
+	Convey("Scenario: parsing Content-Range", t, func() {
+		tests := []struct {
+			in                 string
+			first, last, total int64
+			valid              bool
+		}{
+			{in: "bytes 0-499/1234", first: 0, last: 499, total: 1234, valid: true},
+			{in: "bytes 500-999/*", first: 500, last: 999, total: -1, valid: true},
+			{in: "bytes */1234", first: -1, last: -1, total: 1234, valid: true},
+			{in: "bytes */*"},
+			{in: "bytes 500-499/1234"},
+			{in: "bytes 0-1234/1234"},
+			{in: "bytes 0-"},
+			{in: "items 0-1/2"},
+			{in: ""},
+		}
+		for _, test := range tests {
+			first, last, total, err := parseContentRange(test.in)
+			if !test.valid {
+				So(err, ShouldNotBeNil)
+				continue
+			}
+			So(err, ShouldBeNil)
+			So([]int64{first, last, total}, ShouldResemble, []int64{test.first, test.last, test.total})
+		}
+	})
+}
+
+func TestContentRangeValidation(t *testing.T) {
+	Convey("Scenario: testing Content-Range validation", t, func() {
+		content := newTestContent()
+		var shift int64
+		var total string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Accept-Ranges", "bytes")
+			if req.Method == "HEAD" {
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				return
+			}
+			start, _ := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(req.Header.Get("Range"), "bytes="), "-"), 10, 64)
+			start -= shift
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%s", start, len(content)-1, total))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[start:])
+		}))
+		defer server.Close()
+
+		read := func(opts ...Option) (string, error) {
+			r, err := Open(context.Background(), server.URL, opts...)
+			So(err, ShouldBeNil)
+			defer r.Close()
+			r.Seek(400, io.SeekStart)
+			buf := make([]byte, 4)
+			_, err = io.ReadFull(r, buf)
+			return string(buf), err
+		}
+
+		Convey("Matching ranges are accepted", func() {
+			shift, total = 0, strconv.Itoa(len(content))
+			s, err := read()
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, "0100")
+		})
+
+		Convey("Ranges starting elsewhere are rejected", func() {
+			shift, total = 4, strconv.Itoa(len(content))
+			_, err := read()
+			So(errors.Is(err, ErrContentRangeMismatch), ShouldBeTrue)
+		})
+
+		Convey("Ranges starting earlier can be tolerated", func() {
+			shift, total = 4, strconv.Itoa(len(content))
+			s, err := read(WithTolerateEarlierStart(true))
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, "0100")
+		})
+
+		Convey("Ranges with another total size are rejected", func() {
+			shift, total = 0, strconv.Itoa(len(content)+1)
+			_, err := read()
+			So(errors.Is(err, ErrContentRangeMismatch), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,144 @@
+package httprs
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ReadContext is like Read, ctx bounding the call : range requests are sent with ctx, and the
+// response body being read is aborted if ctx is done before ReadContext returns. An aborted
+// body is replaced by a new range request on the next call, as is a body requested by a
+// previous call whose context is done.
+//
+// Range requests and bodies still end when the context of the reader is done.
+func (r *HttpReadSeeker) ReadContext(ctx context.Context, p []byte) (n int, err error) {
+	stop := r.watch(ctx)
+	n, err = r.read(ctx, p)
+	if stop() {
+		r.discardAborted()
+		if err != nil {
+			err = ctx.Err()
+		}
+	}
+	return n, err
+}
+
+// ReadAtContext is like ReadAt, the range request being sent with ctx.
+func (r *HttpReadSeeker) ReadAtContext(ctx context.Context, p []byte, off int64) (n int, err error) {
+	return r.readAt(ctx, p, off)
+}
+
+// SeekContext is like Seek, ctx bounding the range requests or reads it may do (see Size, and
+// WithShortSeekBytes).
+func (r *HttpReadSeeker) SeekContext(ctx context.Context, offset int64, whence int) (int64, error) {
+	stop := r.watch(ctx)
+	pos, err := r.seek(ctx, offset, whence)
+	if stop() {
+		r.discardAborted()
+		if err != nil {
+			err = ctx.Err()
+		}
+	}
+	return pos, err
+}
+
+// SizeContext is like Size, the range request it may do being sent with ctx.
+func (r *HttpReadSeeker) SizeContext(ctx context.Context) (int64, error) {
+	return r.size(ctx)
+}
+
+// ctxReader reads from r with a context
+type ctxReader struct {
+	r   *HttpReadSeeker
+	ctx context.Context
+}
+
+func (c ctxReader) Read(p []byte) (int, error) {
+	return c.r.read(c.ctx, p)
+}
+
+// bodyContext returns the context of a range request sent for a call with ctx. It carries the
+// values and deadline of ctx, and is also canceled when the context of the reader is done.
+func (r *HttpReadSeeker) bodyContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	bodyCtx, cancel := context.WithCancel(ctx)
+	if ctx != r.ctx {
+		go func() {
+			select {
+			case <-r.ctx.Done():
+				cancel()
+			case <-bodyCtx.Done():
+			}
+		}()
+	}
+	return bodyCtx, cancel
+}
+
+// bodyExpired returns true if the current body failed because the context of the call which
+// requested it is done, while ctx and the context of the reader are not
+func (r *HttpReadSeeker) bodyExpired(ctx context.Context) bool {
+	return r.bodyCtx != nil && r.bodyCtx.Err() != nil && ctx.Err() == nil && r.ctx.Err() == nil
+}
+
+// setAbort sets how the request of the current body is aborted, releasing the previous one.
+// If the context of the current call is already done, the request is aborted right away.
+func (r *HttpReadSeeker) setAbort(abort func()) {
+	r.bodyMu.Lock()
+	defer r.bodyMu.Unlock()
+	if r.abort != nil {
+		r.abort()
+	}
+	r.abort = abort
+	if abort != nil && r.aborted {
+		abort()
+	}
+}
+
+// currentAbort returns a function aborting the request of the current body
+func (r *HttpReadSeeker) currentAbort() func() {
+	r.bodyMu.Lock()
+	defer r.bodyMu.Unlock()
+	if abort := r.abort; abort != nil {
+		return abort
+	}
+	return func() {}
+}
+
+// watch aborts the request of the current body if ctx is done before the returned function is
+// called. That function returns true if it was.
+func (r *HttpReadSeeker) watch(ctx context.Context) func() bool {
+	if ctx.Done() == nil {
+		return func() bool { return false }
+	}
+	stop := make(chan struct{})
+	done := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.bodyMu.Lock()
+			r.aborted = true
+			if r.abort != nil {
+				r.abort()
+			}
+			r.bodyMu.Unlock()
+			done <- true
+		case <-stop:
+			done <- false
+		}
+	}()
+	return func() bool {
+		close(stop)
+		return <-done
+	}
+}
+
+// discardAborted closes the body aborted by watch
+func (r *HttpReadSeeker) discardAborted() {
+	r.bodyMu.Lock()
+	r.aborted = false
+	r.bodyMu.Unlock()
+	if r.r != nil {
+		r.r.Close()
+		r.r = nil
+		atomic.AddInt64(&r.stats.discardedBodies, 1)
+	}
+}
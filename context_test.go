@@ -0,0 +1,165 @@
+package httprs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestContext(t *testing.T) {
+	Convey("Scenario: testing context-aware calls", t, func() {
+		content := newTestContent()
+		serve := serveTestContent(content, map[string]int{})
+		var (
+			mu      sync.Mutex
+			gets    int
+			stalled = make(chan struct{}, 1)
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			if req.Header.Get("Range") == "bytes=0-" {
+				gets++
+			}
+			first := gets == 1
+			mu.Unlock()
+			if !first || req.Header.Get("Range") != "bytes=0-" {
+				serve(w, req)
+				return
+			}
+			// the first body stalls after 100 bytes, until the request is aborted
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(content)-1, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[:100])
+			w.(http.Flusher).Flush()
+			<-req.Context().Done()
+			stalled <- struct{}{}
+		}))
+		defer server.Close()
+
+		r, err := Open(context.Background(), server.URL)
+		So(err, ShouldBeNil)
+		defer r.Close()
+		buf := make([]byte, 100)
+
+		Convey("A stalled body is aborted when the context is done", func() {
+			_, err := io.ReadFull(ctxReader{r: r, ctx: context.Background()}, buf)
+			So(err, ShouldBeNil)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, err = r.ReadContext(ctx, buf)
+			So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+			So(time.Since(start), ShouldBeLessThan, 5*time.Second)
+			select {
+			case <-stalled:
+			case <-time.After(5 * time.Second):
+				So("the request was not aborted", ShouldBeEmpty)
+			}
+
+			Convey("The next call reads from a new range request", func() {
+				n, err := r.ReadContext(context.Background(), buf[:4])
+				So(err, ShouldBeNil)
+				So(string(buf[:n]), ShouldEqual, "0025")
+				So(r.Stats().DiscardedBodies, ShouldEqual, 1)
+			})
+		})
+
+		Convey("Calls with a done context fail", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := r.ReadContext(ctx, buf)
+			So(errors.Is(err, context.Canceled), ShouldBeTrue)
+			_, err = r.ReadAtContext(ctx, buf, 100)
+			So(errors.Is(err, context.Canceled), ShouldBeTrue)
+			_, err = r.SeekContext(ctx, 100, io.SeekCurrent)
+			So(err, ShouldBeNil)
+
+			Convey("Without affecting the reader", func() {
+				n, err := r.ReadAtContext(context.Background(), buf[:4], 100)
+				So(err, ShouldBeNil)
+				So(string(buf[:n]), ShouldEqual, "0025")
+			})
+		})
+
+		Convey("Seeking from the end uses the context when the size is unknown", func() {
+			r.state.size = -1
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := r.SeekContext(ctx, -4, io.SeekEnd)
+			So(errors.Is(err, context.Canceled), ShouldBeTrue)
+			_, err = r.SizeContext(ctx)
+			So(errors.Is(err, context.Canceled), ShouldBeTrue)
+
+			pos, err := r.SeekContext(context.Background(), -4, io.SeekEnd)
+			So(err, ShouldBeNil)
+			So(pos, ShouldEqual, len(content)-4)
+		})
+	})
+
+	Convey("Scenario: testing the context of range requests", t, func() {
+		content := newTestContent()
+		serve := serveTestContent(content, map[string]int{})
+		var (
+			mu     sync.Mutex
+			bodies int
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			bodies++
+			first := bodies == 2 // after the probe
+			mu.Unlock()
+			if !first {
+				serve(w, req)
+				return
+			}
+			// the first body stops after 100 bytes, until the request is aborted
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(content)-1, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[:100])
+			w.(http.Flusher).Flush()
+			<-req.Context().Done()
+		}))
+		defer server.Close()
+
+		type key struct{}
+		var values []interface{}
+		hooks := Hooks{BeforeRequest: func(req *http.Request) {
+			values = append(values, req.Context().Value(key{}))
+		}}
+		r, err := Open(context.Background(), server.URL, WithHooks(hooks))
+		So(err, ShouldBeNil)
+		defer r.Close()
+		values = nil
+
+		ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "read"))
+		defer cancel()
+		buf := make([]byte, 4)
+		_, err = r.ReadContext(ctx, buf)
+		So(err, ShouldBeNil)
+		So(string(buf), ShouldEqual, "0000")
+		_, err = r.ReadAtContext(context.WithValue(context.Background(), key{}, "readat"), buf, 100)
+		So(err, ShouldBeNil)
+
+		Convey("Range requests are sent with the context of the call", func() {
+			So(values, ShouldResemble, []interface{}{"read", "readat"})
+		})
+
+		Convey("A body requested by a call whose context is done is replaced", func() {
+			cancel()
+			b, err := ioutil.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, string(content[4:]))
+			So(values, ShouldResemble, []interface{}{"read", "readat", nil})
+		})
+	})
+}
@@ -0,0 +1,260 @@
+package httprs
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const diskCacheValidatorFile = "validator"
+
+// A DiskCache is a Cache storing blocks as files in a directory, so that they can be
+// reused by later processes. Blocks of a URL are only kept while its validator (ETag or
+// Last-Modified) stays the same : they are removed as soon as a block is requested or
+// stored with another validator. Blocks without validator are never cached.
+//
+// When the size of the stored blocks exceeds the limit, the least recently used ones
+// are removed. The directory must not be shared by several processes at the same time.
+type DiskCache struct {
+	hits      int64 // accessed atomically, kept first for 64-bit alignment
+	misses    int64
+	evictions int64
+
+	dir string
+	bs  int64
+	max int64
+
+	mu         sync.Mutex // protects the fields below
+	size       int64
+	lru        *list.List // of *diskBlock, most recently used first
+	blocks     map[string]*list.Element
+	validators map[string]string // by URL directory
+}
+
+type diskBlock struct {
+	path string // relative to the cache directory
+	size int64
+}
+
+var _ Cache = (*DiskCache)(nil)
+var _ Invalidator = (*DiskCache)(nil)
+
+// NewDiskCache returns a DiskCache storing blocks of blockSize bytes in dir, using at
+// most maxBytes bytes of disk space. Blocks stored by previous processes are reused.
+func NewDiskCache(dir string, blockSize, maxBytes int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	c := &DiskCache{
+		dir:        dir,
+		bs:         blockSize,
+		max:        maxBytes,
+		lru:        list.New(),
+		blocks:     make(map[string]*list.Element),
+		validators: make(map[string]string),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load indexes the blocks already stored in the cache directory
+func (c *DiskCache) load() error {
+	type stored struct {
+		diskBlock
+		mtime time.Time
+	}
+	var all []stored
+	dirs, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		v, err := ioutil.ReadFile(filepath.Join(c.dir, d.Name(), diskCacheValidatorFile))
+		if err != nil {
+			os.RemoveAll(filepath.Join(c.dir, d.Name()))
+			continue
+		}
+		c.validators[d.Name()] = string(v)
+		files, err := ioutil.ReadDir(filepath.Join(c.dir, d.Name()))
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if f.Name() == diskCacheValidatorFile {
+				continue
+			}
+			if _, err := strconv.ParseInt(f.Name(), 10, 64); err != nil {
+				// leftover of an interrupted Put
+				os.Remove(filepath.Join(c.dir, d.Name(), f.Name()))
+				continue
+			}
+			all = append(all, stored{
+				diskBlock: diskBlock{path: filepath.Join(d.Name(), f.Name()), size: f.Size()},
+				mtime:     f.ModTime(),
+			})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].mtime.After(all[j].mtime) })
+	for _, b := range all {
+		b := b.diskBlock
+		c.blocks[b.path] = c.lru.PushBack(&b)
+		c.size += b.size
+	}
+	c.evict()
+	return nil
+}
+
+// BlockSize returns the size of cached blocks
+func (c *DiskCache) BlockSize() int64 {
+	return c.bs
+}
+
+// urlDir returns the directory of the blocks of url, relative to the cache directory
+func (c *DiskCache) urlDir(url string) string {
+	return fmt.Sprintf("%x-%d", sha256.Sum256([]byte(url)), c.bs)
+}
+
+// validate removes the blocks of the directory if they have another validator.
+// It returns false if the directory cannot be used. c.mu must be held.
+func (c *DiskCache) validate(dir, validator string) bool {
+	if v, ok := c.validators[dir]; ok && v == validator {
+		return true
+	}
+	c.removeDir(dir)
+	if err := os.MkdirAll(filepath.Join(c.dir, dir), 0755); err != nil {
+		return false
+	}
+	if err := ioutil.WriteFile(filepath.Join(c.dir, dir, diskCacheValidatorFile), []byte(validator), 0644); err != nil {
+		return false
+	}
+	c.validators[dir] = validator
+	return true
+}
+
+// removeDir removes a URL directory and all its blocks. c.mu must be held.
+func (c *DiskCache) removeDir(dir string) {
+	prefix := dir + string(filepath.Separator)
+	for path, e := range c.blocks {
+		if strings.HasPrefix(path, prefix) {
+			c.lru.Remove(e)
+			delete(c.blocks, path)
+			c.size -= e.Value.(*diskBlock).size
+		}
+	}
+	delete(c.validators, dir)
+	os.RemoveAll(filepath.Join(c.dir, dir))
+}
+
+// Get returns the block for key, if cached
+func (c *DiskCache) Get(key BlockKey) ([]byte, bool) {
+	if key.Validator == "" {
+		return nil, false
+	}
+	dir := c.urlDir(key.URL)
+	path := filepath.Join(dir, strconv.FormatInt(key.Index, 10))
+	c.mu.Lock()
+	valid := c.validate(dir, key.Validator)
+	e, ok := c.blocks[path]
+	ok = ok && valid
+	if ok {
+		c.lru.MoveToFront(e)
+	}
+	c.mu.Unlock()
+	var b []byte
+	if ok {
+		var err error
+		b, err = ioutil.ReadFile(filepath.Join(c.dir, path))
+		ok = (err == nil)
+	}
+	if !ok {
+		atomic.AddInt64(&c.misses, 1)
+		return nil, false
+	}
+	now := time.Now()
+	os.Chtimes(filepath.Join(c.dir, path), now, now) // keeps the LRU order for next processes
+	atomic.AddInt64(&c.hits, 1)
+	return b, true
+}
+
+// Put stores a block, evicting old blocks if needed
+func (c *DiskCache) Put(key BlockKey, block []byte) {
+	if key.Validator == "" || int64(len(block)) > c.max {
+		return
+	}
+	dir := c.urlDir(key.URL)
+	path := filepath.Join(dir, strconv.FormatInt(key.Index, 10))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.validate(dir, key.Validator) {
+		return
+	}
+	tmp, err := ioutil.TempFile(filepath.Join(c.dir, dir), "tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(block)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(c.dir, path))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if e, ok := c.blocks[path]; ok {
+		c.lru.Remove(e)
+		c.size -= e.Value.(*diskBlock).size
+	}
+	c.blocks[path] = c.lru.PushFront(&diskBlock{path: path, size: int64(len(block))})
+	c.size += int64(len(block))
+	c.evict()
+}
+
+// evict removes the least recently used blocks until the cache fits. c.mu must be held.
+func (c *DiskCache) evict() {
+	for c.size > c.max {
+		b := c.lru.Remove(c.lru.Back()).(*diskBlock)
+		delete(c.blocks, b.path)
+		c.size -= b.size
+		os.Remove(filepath.Join(c.dir, b.path))
+		atomic.AddInt64(&c.evictions, 1)
+	}
+}
+
+// Invalidate removes all blocks of url
+func (c *DiskCache) Invalidate(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeDir(c.urlDir(url))
+}
+
+// Stats returns the counters of the cache
+func (c *DiskCache) Stats() CacheStats {
+	c.mu.Lock()
+	size, blocks := c.size, c.lru.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Hits:      atomic.LoadInt64(&c.hits),
+		Misses:    atomic.LoadInt64(&c.misses),
+		Evictions: atomic.LoadInt64(&c.evictions),
+		Bytes:     size,
+		Blocks:    blocks,
+	}
+}
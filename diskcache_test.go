@@ -0,0 +1,127 @@
+package httprs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDiskCache(t *testing.T) {
+	Convey("Scenario: testing DiskCache", t, func() {
+		dir, err := ioutil.TempDir("", "httprs")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		key := func(i int64, validator string) BlockKey {
+			return BlockKey{URL: "http://www.example.com", Validator: validator, Index: i}
+		}
+
+		Convey("Blocks survive a new cache instance", func() {
+			c, err := NewDiskCache(dir, 4, 1024)
+			So(err, ShouldBeNil)
+			c.Put(key(0, `"v1"`), []byte("0000"))
+			c.Put(key(1, `"v1"`), []byte("0001"))
+
+			c, err = NewDiskCache(dir, 4, 1024)
+			So(err, ShouldBeNil)
+			b, ok := c.Get(key(1, `"v1"`))
+			So(ok, ShouldBeTrue)
+			So(string(b), ShouldEqual, "0001")
+			So(c.Stats(), ShouldResemble, CacheStats{Hits: 1, Bytes: 8, Blocks: 2})
+		})
+
+		Convey("Blocks are removed when the validator changes", func() {
+			c, err := NewDiskCache(dir, 4, 1024)
+			So(err, ShouldBeNil)
+			c.Put(key(0, `"v1"`), []byte("0000"))
+			_, ok := c.Get(key(0, `"v2"`))
+			So(ok, ShouldBeFalse)
+			_, ok = c.Get(key(0, `"v1"`))
+			So(ok, ShouldBeFalse)
+			So(c.Stats().Blocks, ShouldEqual, 0)
+		})
+
+		Convey("Blocks without validator are not cached", func() {
+			c, err := NewDiskCache(dir, 4, 1024)
+			So(err, ShouldBeNil)
+			c.Put(key(0, ""), []byte("0000"))
+			_, ok := c.Get(key(0, ""))
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Least recently used blocks are evicted", func() {
+			c, err := NewDiskCache(dir, 4, 8)
+			So(err, ShouldBeNil)
+			c.Put(key(0, `"v1"`), []byte("0000"))
+			c.Put(key(1, `"v1"`), []byte("0001"))
+			c.Get(key(0, `"v1"`))
+			c.Put(key(2, `"v1"`), []byte("0002"))
+			_, ok := c.Get(key(1, `"v1"`))
+			So(ok, ShouldBeFalse)
+			_, ok = c.Get(key(0, `"v1"`))
+			So(ok, ShouldBeTrue)
+			So(c.Stats().Evictions, ShouldEqual, 1)
+			So(c.Stats().Bytes, ShouldEqual, 8)
+		})
+
+		Convey("Readers reuse blocks stored by previous readers", func() {
+			methods := map[string]int{}
+			server := httptest.NewServer(serveTestContent(newTestContent(), methods))
+			defer server.Close()
+
+			for i := 0; i < 2; i++ {
+				c, err := NewDiskCache(dir, 1024, 1024*1024)
+				So(err, ShouldBeNil)
+				r, err := Open(context.Background(), server.URL, WithCache(c))
+				So(err, ShouldBeNil)
+				_, err = r.Seek(-4, io.SeekEnd)
+				So(err, ShouldBeNil)
+				buf := make([]byte, 4)
+				_, err = io.ReadFull(r, buf)
+				So(err, ShouldBeNil)
+				So(string(buf), ShouldEqual, "4095")
+				r.Close()
+			}
+			So(methods, ShouldResemble, map[string]int{"HEAD": 2, "GET": 1})
+		})
+
+		Convey("Blocks are invalidated when the content has changed", func() {
+			var changed int32
+			serve := serveTestContent(newTestContent(), map[string]int{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if atomic.LoadInt32(&changed) != 0 {
+					// only Last-Modified changes, there is no ETag
+					modtime := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+					http.ServeContent(w, req, "file", modtime, bytes.NewReader(make([]byte, SZ*4)))
+					return
+				}
+				serve(w, req)
+			}))
+			defer server.Close()
+
+			c, err := NewDiskCache(dir, 1024, 1024*1024)
+			So(err, ShouldBeNil)
+			r, err := Open(context.Background(), server.URL, WithCache(c))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			buf := make([]byte, 4)
+			_, err = r.ReadAt(buf, 1024)
+			So(err, ShouldBeNil)
+			So(c.Stats().Blocks, ShouldEqual, 1)
+
+			atomic.StoreInt32(&changed, 1)
+			_, err = r.ReadAt(buf, 0)
+			So(errors.Is(err, ErrContentHasChanged), ShouldBeTrue)
+			So(c.Stats().Blocks, ShouldEqual, 0)
+		})
+	})
+}
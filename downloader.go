@@ -0,0 +1,274 @@
+package httprs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrChecksumMismatch is returned by Downloader.Download when the downloaded file does not
+// have the expected checksum
+var ErrChecksumMismatch = errors.New("Checksum of the downloaded file does not match")
+
+// checkpointInterval is how often the progress of a download is saved
+var checkpointInterval = time.Second
+
+// A Downloader downloads a URL to a file using concurrent range requests, writing each
+// range at its offset in the file.
+//
+// Progress is recorded in a checkpoint file next to the destination, so that an interrupted
+// download only fetches the missing ranges when started again. The checkpoint is saved every
+// second and when the download fails, once the written ranges have been synced to disk. It
+// is discarded if the destination was removed or resized in between. If the
+// ETag/Last-Modified of the content has changed, Download fails with ErrContentHasChanged,
+// and the destination and checkpoint files must be removed to start again.
+//
+// When the server does not support range requests, the whole content is downloaded again
+// each time.
+type Downloader struct {
+	// URL to download
+	URL string
+	// Path of the destination file. The checkpoint is stored in Path + ".httprs".
+	Path string
+	// Options used to open URL, e.g. WithClient, WithConcurrency or WithChunkSize
+	Options []Option
+	// Progress, if set, is called each time a range has been written, with the number of
+	// bytes already downloaded and the size of the content. Calls are not concurrent.
+	Progress func(done, total int64)
+	// Hash, if set, is used to compute the checksum of the downloaded file, which must be
+	// equal to Checksum.
+	Hash     func() hash.Hash
+	Checksum []byte
+}
+
+// downloadCheckpoint is the progress of a download, as stored in the checkpoint file
+type downloadCheckpoint struct {
+	URL       string `json:"url"`
+	Size      int64  `json:"size"`
+	Validator string `json:"validator"`
+	ChunkSize int64  `json:"chunk_size"`
+	Done      []bool `json:"done"`
+}
+
+func (d *Downloader) checkpointPath() string {
+	return d.Path + ".httprs"
+}
+
+// Download downloads the URL, resuming a previous download if a checkpoint is found.
+func (d *Downloader) Download(ctx context.Context) error {
+	r, err := Open(ctx, d.URL, d.Options...)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	_, err = os.Stat(d.Path)
+	existed := err == nil
+	f, err := os.OpenFile(d.Path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	size, err := r.Size()
+	if r.canSeek && err == nil {
+		err = d.download(ctx, r, f, size, existed)
+	} else {
+		err = d.copy(r, f)
+	}
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := d.verify(f); err != nil {
+		os.Remove(d.checkpointPath())
+		return err
+	}
+	// a download done in less than a checkpoint interval has no checkpoint
+	if err := os.Remove(d.checkpointPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// download fetches the chunks which are not done yet. existed is false if f was created by
+// Download.
+func (d *Downloader) download(ctx context.Context, r *HttpReadSeeker, f *os.File, size int64, existed bool) error {
+	cp, err := d.loadCheckpoint()
+	if err != nil {
+		return err
+	}
+	if cp != nil {
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if !existed || fi.Size() != cp.Size {
+			// the chunks of the checkpoint are not in the destination anymore
+			cp = nil
+		}
+	}
+	validator := r.validator()
+	switch {
+	case cp == nil || validator == "":
+		cp = &downloadCheckpoint{URL: d.URL, Size: size, Validator: validator, ChunkSize: r.chunkSize}
+	case cp.URL != d.URL || cp.Size != size || cp.Validator != validator:
+		return ErrContentHasChanged
+	default:
+		// keep the chunks of the previous download
+		r.chunkSize = cp.ChunkSize
+	}
+	all := r.chunks(0, size)
+	if len(cp.Done) != len(all) {
+		cp.Done = make([]bool, len(all))
+	}
+	if err := f.Truncate(size); err != nil {
+		return err
+	}
+
+	var missing []chunk
+	var done int64
+	for i, c := range all {
+		if cp.Done[i] {
+			done += c.length
+		} else {
+			missing = append(missing, c)
+		}
+	}
+	if d.Progress != nil {
+		d.Progress(done, size)
+	}
+
+	var (
+		mu    sync.Mutex
+		saved = time.Now()
+	)
+	err = r.forEachChunk(ctx, missing, func(c chunk, b []byte) error {
+		if _, err := f.WriteAt(b, c.off); err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		cp.Done[c.off/cp.ChunkSize] = true
+		done += c.length
+		if time.Since(saved) >= checkpointInterval {
+			if err := d.checkpoint(f, cp); err != nil {
+				return err
+			}
+			saved = time.Now()
+		}
+		if d.Progress != nil {
+			d.Progress(done, size)
+		}
+		return nil
+	})
+	if err != nil {
+		// keep the chunks written so far for the next download
+		d.checkpoint(f, cp)
+	}
+	return err
+}
+
+// checkpoint saves the progress, once the chunks marked as done are on disk
+func (d *Downloader) checkpoint(f *os.File, cp *downloadCheckpoint) error {
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return d.saveCheckpoint(cp)
+}
+
+// copy downloads the whole body, when range requests cannot be used
+func (d *Downloader) copy(r *HttpReadSeeker, f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	var w io.Writer = f
+	if d.Progress != nil {
+		total, _ := r.knownSize()
+		w = &progressWriter{w: f, total: total, progress: d.Progress}
+	}
+	_, err := io.Copy(w, r)
+	return err
+}
+
+type progressWriter struct {
+	w        io.Writer
+	done     int64
+	total    int64
+	progress func(done, total int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.done += int64(n)
+	p.progress(p.done, p.total)
+	return n, err
+}
+
+// verify checks the checksum of the downloaded file
+func (d *Downloader) verify(f *os.File) error {
+	if d.Hash == nil {
+		return nil
+	}
+	h := d.Hash()
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, 1<<62)); err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), d.Checksum) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// loadCheckpoint returns the checkpoint of a previous download, or nil if there is none
+func (d *Downloader) loadCheckpoint() (*downloadCheckpoint, error) {
+	b, err := ioutil.ReadFile(d.checkpointPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cp := &downloadCheckpoint{}
+	if err := json.Unmarshal(b, cp); err != nil || cp.ChunkSize <= 0 {
+		// unreadable checkpoint, start again
+		return nil, nil
+	}
+	return cp, nil
+}
+
+// saveCheckpoint atomically replaces the checkpoint file
+func (d *Downloader) saveCheckpoint(cp *downloadCheckpoint) error {
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(d.Path), filepath.Base(d.checkpointPath()))
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), d.checkpointPath())
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package httprs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDownloader(t *testing.T) {
+	Convey("Scenario: testing Downloader", t, func() {
+		content := newTestContent()
+		dir, err := ioutil.TempDir("", "httprs")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		var (
+			modtime  = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			failFrom = -1 // fail range requests starting at or after this offset
+			ranges   []string
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if rng := req.Header.Get("Range"); rng != "" {
+				ranges = append(ranges, rng)
+				start, _ := strconv.Atoi(strings.Split(strings.TrimPrefix(rng, "bytes="), "-")[0])
+				if failFrom >= 0 && start >= failFrom {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+			}
+			http.ServeContent(w, req, "file", modtime, bytes.NewReader(content))
+		}))
+		defer server.Close()
+
+		d := &Downloader{
+			URL:     server.URL,
+			Path:    filepath.Join(dir, "file"),
+			Options: []Option{WithChunkSize(1000), WithConcurrency(1)},
+		}
+
+		Convey("A complete download writes the file and removes the checkpoint", func() {
+			var progress, totals []int64
+			d.Progress = func(done, total int64) {
+				progress = append(progress, done)
+				totals = append(totals, total)
+			}
+			d.Hash = sha256.New
+			sum := sha256.Sum256(content)
+			d.Checksum = sum[:]
+			So(d.Download(context.Background()), ShouldBeNil)
+			b, err := ioutil.ReadFile(d.Path)
+			So(err, ShouldBeNil)
+			So(bytes.Equal(b, content), ShouldBeTrue)
+			So(totals[0], ShouldEqual, len(content))
+			So(progress[0], ShouldEqual, 0)
+			So(progress[len(progress)-1], ShouldEqual, len(content))
+			_, err = os.Stat(d.Path + ".httprs")
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("An interrupted download only fetches the missing ranges", func() {
+			failFrom = 5000
+			err := d.Download(context.Background())
+			So(err, ShouldNotBeNil)
+			_, err = os.Stat(d.Path + ".httprs")
+			So(err, ShouldBeNil)
+
+			failFrom = -1
+			ranges = nil
+			So(d.Download(context.Background()), ShouldBeNil)
+			So(ranges, ShouldNotContain, "bytes=0-999")
+			So(ranges, ShouldContain, "bytes=5000-5999")
+			So(len(ranges), ShouldEqual, 12)
+			b, err := ioutil.ReadFile(d.Path)
+			So(err, ShouldBeNil)
+			So(bytes.Equal(b, content), ShouldBeTrue)
+		})
+
+		Convey("A download starts again if the destination was removed", func() {
+			failFrom = 5000
+			So(d.Download(context.Background()), ShouldNotBeNil)
+			So(os.Remove(d.Path), ShouldBeNil)
+
+			failFrom = -1
+			ranges = nil
+			So(d.Download(context.Background()), ShouldBeNil)
+			So(ranges, ShouldContain, "bytes=0-999")
+			So(len(ranges), ShouldEqual, 17)
+			b, err := ioutil.ReadFile(d.Path)
+			So(err, ShouldBeNil)
+			So(bytes.Equal(b, content), ShouldBeTrue)
+		})
+
+		Convey("A download starts again if the destination was truncated", func() {
+			failFrom = 5000
+			So(d.Download(context.Background()), ShouldNotBeNil)
+			So(os.Truncate(d.Path, 100), ShouldBeNil)
+
+			failFrom = -1
+			ranges = nil
+			So(d.Download(context.Background()), ShouldBeNil)
+			So(len(ranges), ShouldEqual, 17)
+			b, err := ioutil.ReadFile(d.Path)
+			So(err, ShouldBeNil)
+			So(bytes.Equal(b, content), ShouldBeTrue)
+		})
+
+		Convey("Progress is saved in the checkpoint while downloading", func() {
+			defer func(interval time.Duration) { checkpointInterval = interval }(checkpointInterval)
+			checkpointInterval = 0
+			var checkpoints []int
+			d.Progress = func(done, total int64) {
+				cp, _ := d.loadCheckpoint()
+				n := 0
+				if cp != nil {
+					for _, ok := range cp.Done {
+						if ok {
+							n++
+						}
+					}
+				}
+				checkpoints = append(checkpoints, n)
+			}
+			So(d.Download(context.Background()), ShouldBeNil)
+			So(checkpoints, ShouldResemble, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17})
+		})
+
+		Convey("A download is not resumed if the content has changed", func() {
+			failFrom = 5000
+			So(d.Download(context.Background()), ShouldNotBeNil)
+			failFrom = -1
+			modtime = modtime.Add(time.Hour)
+			err := d.Download(context.Background())
+			So(errors.Is(err, ErrContentHasChanged), ShouldBeTrue)
+		})
+
+		Convey("A checksum mismatch is reported", func() {
+			d.Hash = sha256.New
+			d.Checksum = []byte("wrong")
+			err := d.Download(context.Background())
+			So(err, ShouldEqual, ErrChecksumMismatch)
+		})
+	})
+}
@@ -1,6 +1,6 @@
 module github.com/jfbus/httprs
 
-go 1.12
+go 1.13
 
 require (
 	github.com/mitchellh/copystructure v1.0.0
@@ -10,8 +10,21 @@ Usage :
 	rs.Seek(1024, 0) // moves the position, but does no range request
 	io.ReadFull(rs, buf) // does a range request and reads from the response body
 
+If you do not need the beginning of the body, Open only does a HEAD request, and fetches
+the body by range requests :
+
+	rs, err := httprs.Open(ctx, url)
+
 If you want use a specific http.Client for additional range requests :
+
 	rs := httprs.NewHttpReadSeeker(resp, client)
+
+Other settings are available through options :
+
+	rs, err := httprs.NewHttpReadSeekerWithOptions(resp,
+		httprs.WithClient(client),
+		httprs.WithShortSeekBytes(64*1024),
+	)
 */
 package httprs
 
@@ -22,11 +35,18 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/mitchellh/copystructure"
 )
 
-const shortSeekBytes = 1024
+const (
+	shortSeekBytes     = 1024
+	defaultConcurrency = 4
+	defaultChunkSize   = 1024 * 1024
+)
 
 // A HttpReadSeeker reads from a http.Response.Body. It can Seek
 // by doing range requests.
@@ -37,16 +57,53 @@ type HttpReadSeeker struct {
 	ctx     context.Context
 	r       io.ReadCloser
 	pos     int64
+	start   int64 // position of the first byte of r
+	end     int64 // end of the range requested for r, -1 if open-ended
 	canSeek bool
 
-	Requests int
+	shortSeek            int64
+	shortSeekSet         bool
+	estimator            *seekEstimator
+	validatorPolicy      ValidatorPolicy
+	tolerateEarlierStart bool
+	concurrency          int
+	chunkSize            int64
+	readAhead            int
+	rewind               int
+	windowMin, windowMax int64
+	window               int64
+	drainLimit           int64
+	stall                StallPolicy
+	coalesceGap          int64
+	coalesceMax          int64
+	retry                RetryPolicy
+	resume               RetryPolicy
+	cache                Cache
+	hooks                Hooks
+	stats                *stats
+
+	state *contentState // shared with clones
+
+	bodyCtx context.Context // context of the call which requested the current body, nil for the initial response
+	bodyMu  sync.Mutex      // protects abort and aborted, used by ReadContext and SeekContext
+	abort   func()          // aborts the request of the current body
+	aborted bool            // the context of the current call is done
+}
+
+// contentState is what is known about the remote content, shared by a reader and its clones
+type contentState struct {
+	mu           sync.Mutex // protects the fields below
+	size         int64      // -1 if unknown
+	noMultiRange bool       // the server answered a multiple range request with 200
 }
 
 var _ io.ReadCloser = (*HttpReadSeeker)(nil)
 var _ io.Seeker = (*HttpReadSeeker)(nil)
 
 var (
-	// ErrNoContentLength is returned by Seek when the initial http response did not include a Content-Length header
+	// ErrNoContentLength is returned by Seek and Size when the size of the content is unknown
+	// (the initial http response did not include a Content-Length header, and range requests
+	// did not tell it)
 	ErrNoContentLength = errors.New("Content-Length was not set")
 	// ErrRangeRequestsNotSupported is returned by Seek and Read
 	// when the remote server does not allow range requests (Accept-Ranges was not set)
@@ -55,88 +112,419 @@ var (
 	ErrInvalidRange = errors.New("Invalid range")
 	// ErrContentHasChanged is returned by Read when the content has changed since the first request
 	ErrContentHasChanged = errors.New("Content has changed since first request")
+	// ErrContentRangeMismatch is returned by Read when the Content-Range of a response does not
+	// match the requested range or the size of the content
+	ErrContentRangeMismatch = errors.New("Content-Range does not match the requested range")
+	// ErrServerUnavailable is returned by Read when the remote server answered a range request
+	// with a transient error (429, 500, 502, 503 or 504), and the retry policy gave up
+	ErrServerUnavailable = errors.New("Remote server is temporarily unavailable")
 )
 
+// A RangeError is returned when a range request fails. It wraps the errors of this
+// package, so that they can be checked with errors.Is.
+type RangeError struct {
+	// Offset is the first requested byte
+	Offset int64
+	// Length is the number of requested bytes, -1 for the rest of the file
+	Length int64
+	// StatusCode is the status of the response, 0 if no response was received
+	StatusCode int
+	// Header is the header of the response, nil if no response was received
+	Header http.Header
+	// Err is the underlying error
+	Err error
+}
+
+func newRangeError(off, length int64, res *http.Response, err error) *RangeError {
+	e := &RangeError{Offset: off, Length: length, Err: err}
+	if res != nil {
+		e.StatusCode = res.StatusCode
+		e.Header = res.Header
+	}
+	return e
+}
+
+func (e *RangeError) Error() string {
+	rng := fmt.Sprintf("bytes=%d-", e.Offset)
+	if e.Length >= 0 {
+		rng += fmt.Sprint(e.Offset + e.Length - 1)
+	}
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("Range request %s failed with status %d: %v", rng, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("Range request %s failed: %v", rng, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *RangeError) Unwrap() error {
+	return e.Err
+}
+
 // NewHttpReadSeeker returns a HttpReadSeeker, using the http.Response and, optionaly, the http.Client
 // that needs to be used for future range requests. If no http.Client is given, http.DefaultClient will
 // be used.
 //
 // res.Request will be reused for range requests, headers may be added/removed
 func NewHttpReadSeeker(res *http.Response, client ...*http.Client) *HttpReadSeeker {
-	r := &HttpReadSeeker{
-		req:     res.Request,
-		ctx:     res.Request.Context(),
-		res:     res,
-		r:       res.Body,
-		canSeek: (res.Header.Get("Accept-Ranges") == "bytes"),
-	}
-	if len(client) > 0 {
-		r.c = client[0]
-	} else {
-		r.c = http.DefaultClient
+	var opts []Option
+	if len(client) > 0 && client[0] != nil {
+		opts = append(opts, WithClient(client[0]))
+	}
+	r, err := NewHttpReadSeekerWithOptions(res, opts...)
+	if err != nil {
+		panic(err)
 	}
 	return r
 }
 
-// Clone clones the reader to enable parallel downloads of ranges
+// NewHttpReadSeekerWithOptions returns a HttpReadSeeker for the http.Response, configured by opts.
+//
+// res may be a 206 Partial Content response, the reader then starts at the first byte of its
+// Content-Range.
+//
+// res.Request will be reused for range requests, headers may be added/removed
+func NewHttpReadSeekerWithOptions(res *http.Response, opts ...Option) (*HttpReadSeeker, error) {
+	if res == nil || res.Request == nil {
+		return nil, errors.New("Response has no request")
+	}
+	r, err := newHttpReadSeeker(res.Request, opts)
+	if err != nil {
+		return nil, err
+	}
+	r.res = res
+	if body := res.Body; body != nil {
+		r.abort = func() { body.Close() }
+		r.r = r.wrapBody(&receivedBody{ReadCloser: body, n: &r.stats.bytesReceived})
+	}
+	r.canSeek = (res.Header.Get("Accept-Ranges") == "bytes")
+	if res.ContentLength > 0 {
+		r.state.size = res.ContentLength
+	}
+	if res.StatusCode == http.StatusPartialContent {
+		// the body starts at the beginning of the range, the size is the one of the whole content
+		first, last, total, err := parseContentRange(res.Header.Get("Content-Range"))
+		r.canSeek = (err == nil)
+		if err == nil {
+			r.pos = first
+			r.start = first
+			r.state.size = total
+			r.end = last + 1
+		}
+	}
+	return r, nil
+}
+
+// newHttpReadSeeker returns a HttpReadSeeker for req, with no response yet
+func newHttpReadSeeker(req *http.Request, opts []Option) (*HttpReadSeeker, error) {
+	r := &HttpReadSeeker{
+		c:           http.DefaultClient,
+		req:         req,
+		ctx:         req.Context(),
+		shortSeek:   shortSeekBytes,
+		concurrency: defaultConcurrency,
+		chunkSize:   defaultChunkSize,
+		end:         -1,
+		stats:       &stats{},
+		state:       &contentState{size: -1},
+	}
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Clone returns a new reader for the same content, starting at the current position, to
+// read ranges in parallel. It uses the same context, client, options, cache and stats as r,
+// and shares what is known about the content (size, validators).
+//
+// The clone has its own position and response body, and must be closed.
 func (r *HttpReadSeeker) Clone() (*HttpReadSeeker, error) {
+	return r.CloneWithContext(r.ctx)
+}
+
+// CloneWithContext is like Clone, the range requests of the clone using ctx.
+func (r *HttpReadSeeker) CloneWithContext(ctx context.Context) (*HttpReadSeeker, error) {
+	if ctx == nil {
+		return nil, errors.New("Context is nil")
+	}
+	if !r.canSeek {
+		return nil, ErrRangeRequestsNotSupported
+	}
 	req, err := copystructure.Copy(r.req)
 	if err != nil {
 		return nil, err
 	}
 	return &HttpReadSeeker{
-		req:     req.(*http.Request),
-		res:     r.res,
-		r:       nil,
-		canSeek: r.canSeek,
-		c:       r.c,
+		req:                  req.(*http.Request),
+		res:                  r.res,
+		ctx:                  ctx,
+		r:                    nil,
+		pos:                  r.pos,
+		end:                  -1,
+		canSeek:              r.canSeek,
+		c:                    r.c,
+		shortSeek:            r.shortSeek,
+		shortSeekSet:         r.shortSeekSet,
+		estimator:            r.estimator,
+		validatorPolicy:      r.validatorPolicy,
+		tolerateEarlierStart: r.tolerateEarlierStart,
+		concurrency:          r.concurrency,
+		chunkSize:            r.chunkSize,
+		readAhead:            r.readAhead,
+		rewind:               r.rewind,
+		windowMin:            r.windowMin,
+		windowMax:            r.windowMax,
+		drainLimit:           r.drainLimit,
+		stall:                r.stall,
+		coalesceGap:          r.coalesceGap,
+		coalesceMax:          r.coalesceMax,
+		retry:                r.retry,
+		resume:               r.resume,
+		cache:                r.cache,
+		hooks:                r.hooks,
+		stats:                r.stats,
+		state:                r.state,
 	}, nil
 }
 
 // Read reads from the response body. It does a range request if Seek was called before.
 //
-// May return ErrRangeRequestsNotSupported, ErrInvalidRange or ErrContentHasChanged
+// If a resume policy was set, a body failing before its end is replaced by a new range
+// request starting at the current position.
+//
+// Failed range requests return a *RangeError, wrapping ErrRangeRequestsNotSupported,
+// ErrInvalidRange, ErrContentHasChanged, ErrContentRangeMismatch, ErrServerUnavailable or a
+// network error. An empty partial response ending before the end of the content returns
+// io.ErrUnexpectedEOF.
 func (r *HttpReadSeeker) Read(p []byte) (n int, err error) {
-	if r.r == nil {
-		err = r.rangeRequest()
+	return r.read(r.ctx, p)
+}
+
+func (r *HttpReadSeeker) read(ctx context.Context, p []byte) (n int, err error) {
+	if r.cache != nil {
+		return r.readCached(ctx, p)
 	}
-	if r.r != nil {
+	continued := false
+	for attempt := 1; ; attempt++ {
+		if r.r == nil {
+			if err = r.rangeRequest(ctx); err != nil {
+				if continued && errors.Is(err, ErrInvalidRange) {
+					// the previous response ended at the end of the content
+					return 0, io.EOF
+				}
+				return 0, err
+			}
+		}
 		n, err = r.r.Read(p)
 		r.pos += int64(n)
+		if err != nil && err != io.EOF && r.bodyExpired(ctx) {
+			// the body was requested by a previous call, whose context is done
+			r.r.Close()
+			r.r = nil
+			atomic.AddInt64(&r.stats.discardedBodies, 1)
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		if err == io.EOF && r.endsEarly() {
+			// partial response, the rest is fetched by the next range request
+			r.r.Close()
+			r.r = nil
+			if n > 0 {
+				return n, nil
+			}
+			if r.pos == r.start {
+				// an empty body would be requested again and again
+				return 0, io.ErrUnexpectedEOF
+			}
+			continued = true
+			continue
+		}
+		if errors.Is(err, ErrStalled) && r.canSeek {
+			// the body was aborted, the next range request starts at the current position
+			r.r.Close()
+			r.r = nil
+			if r.hooks.OnStall != nil {
+				r.hooks.OnStall(r.pos, err)
+			}
+			if n > 0 {
+				atomic.AddInt64(&r.stats.reconnects, 1)
+				return n, nil
+			}
+			if attempt > r.stall.maxReconnects() {
+				return 0, err
+			}
+			atomic.AddInt64(&r.stats.reconnects, 1)
+			continue
+		}
+		if !r.canResume(ctx, err) {
+			return n, err
+		}
+		r.r.Close()
+		r.r = nil
+		if n > 0 {
+			// the next Read will resume
+			atomic.AddInt64(&r.stats.reconnects, 1)
+			return n, nil
+		}
+		delay, ok := r.resume.Retry(attempt, nil, err)
+		if !ok {
+			return 0, err
+		}
+		atomic.AddInt64(&r.stats.reconnects, 1)
+		if serr := sleep(ctx, delay); serr != nil {
+			return 0, err
+		}
+	}
+}
+
+// endsEarly returns true if the current position is before the end of the content, or may be
+// when the size is unknown and the requested range was entirely received
+func (r *HttpReadSeeker) endsEarly() bool {
+	if !r.canSeek {
+		return false
 	}
+	if size, ok := r.knownSize(); ok {
+		return r.pos < size
+	}
+	return r.end >= 0 && r.pos == r.end
+}
+
+// canResume returns true if a body which failed with err can be replaced by a new range request
+func (r *HttpReadSeeker) canResume(ctx context.Context, err error) bool {
+	return err != nil && err != io.EOF &&
+		r.resume != nil && r.canSeek && ctx.Err() == nil && r.ctx.Err() == nil
+}
+
+func (r *HttpReadSeeker) readCached(ctx context.Context, p []byte) (n int, err error) {
+	if r.r != nil {
+		// blocks are fetched by bounded range requests, the streamed body is not needed
+		r.abandon()
+	}
+	n, err = r.readBlocks(ctx, p, r.pos)
+	r.pos += int64(n)
 	return
 }
 
-// ReadAt reads from the response body starting at offset off.
+// ReadAt reads len(p) bytes from offset off, using a dedicated range request, followed by
+// others if the server returns less than the requested range.
+// It neither uses nor moves the position of Read and Seek, and is safe for concurrent use.
 //
-// May return ErrRangeRequestsNotSupported, ErrInvalidRange or ErrContentHasChanged
+// May return ErrRangeRequestsNotSupported, or a *RangeError as Read.
 func (r *HttpReadSeeker) ReadAt(p []byte, off int64) (n int, err error) {
-	var nn int
+	return r.readAt(r.ctx, p, off)
+}
 
-	r.Seek(off, 0)
+func (r *HttpReadSeeker) readAt(ctx context.Context, p []byte, off int64) (n int, err error) {
+	if !r.canSeek {
+		return 0, ErrRangeRequestsNotSupported
+	}
+	if off < 0 {
+		return 0, fmt.Errorf("Invalid offset %d", off)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if r.cache != nil {
+		return r.readBlocks(ctx, p, off)
+	}
+	if size, ok := r.knownSize(); ok && off >= size {
+		return 0, io.EOF
+	}
+	for {
+		body, err := r.fetch(ctx, off+int64(n), int64(len(p)-n))
+		if err != nil {
+			return n, err
+		}
+		nn, err := readFull(body, p[n:])
+		body.Close()
+		n += nn
+		if err != io.EOF {
+			return n, err
+		}
+		size, ok := r.knownSize()
+		if !ok || off+int64(n) >= size {
+			return n, io.EOF
+		}
+		if nn == 0 {
+			// an empty body would be requested again and again
+			return n, io.ErrUnexpectedEOF
+		}
+		// the response ended before the end of the range, the rest is requested again
+	}
+}
 
+// readFull is io.ReadFull, but returns io.EOF when the reader ends before p is full
+func readFull(rd io.Reader, p []byte) (n int, err error) {
+	var nn int
 	for n < len(p) && err == nil {
-		nn, err = r.Read(p[n:])
+		nn, err = rd.Read(p[n:])
 		n += nn
 	}
+	if n == len(p) {
+		err = nil
+	}
 	return
 }
 
 // Close closes the response body
 func (r *HttpReadSeeker) Close() error {
+	defer r.setAbort(nil)
 	if r.r != nil {
-		return r.r.Close()
+		return r.abandon()
 	}
 	return nil
 }
 
+// abandon closes the current response body. If a drain limit was set and few bytes remain
+// in the body, they are read first, so that the connection can be reused.
+func (r *HttpReadSeeker) abandon() error {
+	remaining, known := r.remainingInBody()
+	body := r.r
+	r.r = nil
+	if r.drainLimit > 0 && known && remaining <= r.drainLimit {
+		n, err := io.Copy(ioutil.Discard, io.LimitReader(body, r.drainLimit+1))
+		if err == nil && n <= r.drainLimit {
+			atomic.AddInt64(&r.stats.drainedBodies, 1)
+			return body.Close()
+		}
+	}
+	atomic.AddInt64(&r.stats.discardedBodies, 1)
+	return body.Close()
+}
+
+// remainingInBody returns how many bytes of the current response body are not read yet
+func (r *HttpReadSeeker) remainingInBody() (int64, bool) {
+	end := r.end
+	if end < 0 {
+		size, ok := r.knownSize()
+		if !ok {
+			return 0, false
+		}
+		end = size
+	}
+	pos := r.pos
+	if rb, ok := r.r.(*rewindBody); ok {
+		pos += rb.buffered()
+	}
+	return end - pos, true
+}
+
 // Seek moves the reader position to a new offset.
 //
 // It does not send http requests, allowing for multiple seeks without overhead.
-// The http request will be sent by the next Read call.
+// The http request will be sent by the next Read call. Seeking from the end when the
+// size is not known yet does a range request, see Size.
 //
 // May return ErrNoContentLength or ErrRangeRequestsNotSupported
 func (r *HttpReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return r.seek(r.ctx, offset, whence)
+}
+
+func (r *HttpReadSeeker) seek(ctx context.Context, offset int64, whence int) (int64, error) {
 	if !r.canSeek {
 		return 0, ErrRangeRequestsNotSupported
 	}
@@ -146,29 +534,86 @@ func (r *HttpReadSeeker) Seek(offset int64, whence int) (int64, error) {
 	case 1:
 		offset += r.pos
 	case 2:
-		if r.res.ContentLength <= 0 {
-			return 0, ErrNoContentLength
+		size, err := r.size(ctx)
+		if err != nil {
+			return 0, err
 		}
-		offset = r.res.ContentLength + offset
+		offset = size + offset
 	}
 	if r.r != nil {
+		rb, rewindable := r.r.(*rewindBody)
+		if offset < r.pos && rewindable && rb.rewind(r.pos-offset) {
+			atomic.AddInt64(&r.stats.requestsSaved, 1)
+			r.pos = offset
+			return r.pos, nil
+		}
 		// Try to read, which is cheaper than doing a request
-		if r.pos < offset && offset-r.pos <= shortSeekBytes {
-			_, err := io.CopyN(ioutil.Discard, r, offset-r.pos)
+		if r.pos < offset &&
+			(offset-r.pos <= r.ShortSeekBytes() || (rewindable && offset-r.pos <= rb.buffered())) {
+			n, err := io.CopyN(ioutil.Discard, ctxReader{r: r, ctx: ctx}, offset-r.pos)
+			atomic.AddInt64(&r.stats.bytesDiscarded, n)
 			if err != nil {
 				return 0, err
 			}
 		}
 
 		if r.pos != offset {
-			err = r.r.Close()
-			r.r = nil
+			err = r.abandon()
 		}
 	}
 	r.pos = offset
 	return r.pos, err
 }
 
+// Size returns the size of the remote content.
+//
+// If the initial response had no Content-Length, the size is learnt from the Content-Range
+// of range requests, doing a bytes=0-0 request if none was done yet.
+//
+// May return ErrNoContentLength, or a *RangeError if the range request fails.
+func (r *HttpReadSeeker) Size() (int64, error) {
+	return r.size(r.ctx)
+}
+
+func (r *HttpReadSeeker) size(ctx context.Context) (int64, error) {
+	if size, ok := r.knownSize(); ok {
+		return size, nil
+	}
+	if !r.canSeek {
+		return 0, ErrNoContentLength
+	}
+	body, err := r.fetch(ctx, 0, 1)
+	if err == nil {
+		body.Close()
+	}
+	if size, ok := r.knownSize(); ok {
+		return size, nil
+	}
+	if err != nil && !errors.Is(err, ErrInvalidRange) {
+		return 0, err
+	}
+	return 0, ErrNoContentLength
+}
+
+// knownSize returns the size of the content, if already known
+func (r *HttpReadSeeker) knownSize() (int64, bool) {
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+	return r.state.size, r.state.size >= 0
+}
+
+// learnSize records the size of the content, if not known yet
+func (r *HttpReadSeeker) learnSize(size int64) {
+	if size < 0 {
+		return
+	}
+	r.state.mu.Lock()
+	if r.state.size < 0 {
+		r.state.size = size
+	}
+	r.state.mu.Unlock()
+}
+
 func cloneHeader(h http.Header) http.Header {
 	h2 := make(http.Header, len(h))
 	for k, vv := range h {
@@ -179,8 +624,28 @@ func cloneHeader(h http.Header) http.Header {
 	return h2
 }
 
-func (r *HttpReadSeeker) newRequest() *http.Request {
-	newreq := r.req.WithContext(r.ctx) // includes shallow copies of maps, but okay
+// wrapBody returns the body Read will read from
+func (r *HttpReadSeeker) wrapBody(body io.ReadCloser) io.ReadCloser {
+	if body == nil {
+		return nil
+	}
+	if r.stall.enabled() {
+		body = newStallBody(body, r.stall, r.currentAbort())
+	}
+	if r.estimator != nil {
+		body = &meteredBody{ReadCloser: body, e: r.estimator}
+	}
+	if r.readAhead > 0 {
+		body = newReadAheadBody(body, r.readAhead)
+	}
+	if r.rewind > 0 {
+		body = newRewindBody(body, r.rewind)
+	}
+	return body
+}
+
+func (r *HttpReadSeeker) newRequest(ctx context.Context) *http.Request {
+	newreq := r.req.WithContext(ctx) // includes shallow copies of maps, but okay
 	if r.req.ContentLength == 0 {
 		newreq.Body = nil // Issue 16036: nil Body for http.Transport retries
 	}
@@ -188,36 +653,221 @@ func (r *HttpReadSeeker) newRequest() *http.Request {
 	return newreq
 }
 
-func (r *HttpReadSeeker) rangeRequest() error {
-	r.req = r.newRequest()
-	r.req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.pos))
-	etag, last := r.res.Header.Get("ETag"), r.res.Header.Get("Last-Modified")
-	switch {
-	case last != "":
-		r.req.Header.Set("If-Range", last)
-	case etag != "":
-		r.req.Header.Set("If-Range", etag)
-	}
+// validator returns the If-Range value selected by the validator policy
+func (r *HttpReadSeeker) validator() string {
+	return r.validatorOf(r.res.Header)
+}
 
-	r.Requests++
+// validatorOf returns the validator selected by the validator policy among the headers h
+func (r *HttpReadSeeker) validatorOf(h http.Header) string {
+	etag, last := h.Get("ETag"), h.Get("Last-Modified")
+	switch r.validatorPolicy {
+	case ValidateETag:
+		return etag
+	case ValidateLastModified:
+		return last
+	case ValidateNone:
+		return ""
+	}
+	if last != "" {
+		return last
+	}
+	return etag
+}
 
-	res, err := r.c.Do(r.req)
+// rangeRequest replaces the response body by a range request from the current position.
+// The request is done with its own context, derived from ctx, so that ReadContext can abort it.
+func (r *HttpReadSeeker) rangeRequest(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	length := int64(-1)
+	if r.windowMin > 0 {
+		length = r.nextWindow()
+	}
+	bodyCtx, cancel := r.bodyContext(ctx)
+	r.setAbort(cancel)
+	body, err := r.fetch(bodyCtx, r.pos, length)
 	if err != nil {
 		return err
 	}
+	r.r = r.wrapBody(body)
+	r.bodyCtx = ctx
+	r.start = r.pos
+	r.end = -1
+	if length >= 0 {
+		r.end = r.pos + length
+	}
+	return nil
+}
+
+// nextWindow returns the length of the next bounded range request : twice the previous one
+// when reading sequentially, the minimal one otherwise. The end of an initial 206 response
+// is not a previous window.
+func (r *HttpReadSeeker) nextWindow() int64 {
+	if r.end >= 0 && r.pos == r.end && r.window > 0 {
+		r.window *= 2
+		if r.window > r.windowMax {
+			r.window = r.windowMax
+		}
+	} else {
+		r.window = r.windowMin
+	}
+	return r.window
+}
+
+// fetch does a range request for length bytes starting at off, or up to the end of the
+// file if length is negative, and returns the response body.
+func (r *HttpReadSeeker) fetch(ctx context.Context, off, length int64) (io.ReadCloser, error) {
+	res, err := r.roundTrip(ctx, rangeHeader(off, length))
+	if err == nil {
+		var body io.ReadCloser
+		if body, err = r.checkResponse(res, off); err == nil {
+			return body, nil
+		}
+	}
+	return nil, newRangeError(off, length, res, err)
+}
+
+// rangeHeader returns the Range header for length bytes at off, or up to the end if length is negative
+func rangeHeader(off, length int64) string {
+	if length < 0 {
+		return fmt.Sprintf("bytes=%d-", off)
+	}
+	return fmt.Sprintf("bytes=%d-%d", off, off+length-1)
+}
+
+// roundTrip sends a range request, retrying it while the retry policy allows it. The returned
+// response may be set with an error, to describe the last failure.
+func (r *HttpReadSeeker) roundTrip(ctx context.Context, rng string) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		res, err := r.do(ctx, rng)
+		if err == nil && !isTransient(res.StatusCode) {
+			return res, nil
+		}
+		if err == nil {
+			res.Body.Close()
+			err = ErrServerUnavailable
+		}
+		if r.retry == nil || ctx.Err() != nil {
+			return res, err
+		}
+		delay, ok := r.retry.Retry(attempt, res, err)
+		if !ok {
+			return res, err
+		}
+		if serr := sleep(ctx, delay); serr != nil {
+			return res, serr
+		}
+		atomic.AddInt64(&r.stats.retries, 1)
+	}
+}
+
+func (r *HttpReadSeeker) do(ctx context.Context, rng string) (*http.Response, error) {
+	req := r.newRequest(ctx)
+	req.Header.Set("Range", rng)
+	if v := r.validator(); v != "" {
+		req.Header.Set("If-Range", v)
+	}
+	atomic.AddInt64(&r.stats.rangeRequests, 1)
+
+	start := time.Now()
+	res, err := r.send(req)
+	if err == nil && r.estimator != nil {
+		r.estimator.observeLatency(time.Since(start))
+	}
+	return res, err
+}
+
+// send sends req with the client, tracing it, calling hooks and updating stats
+func (r *HttpReadSeeker) send(req *http.Request) (*http.Response, error) {
+	if r.hooks.BeforeRequest != nil {
+		r.hooks.BeforeRequest(req)
+	}
+	req, t := traced(req)
+	start := time.Now()
+	res, err := r.c.Do(req)
+	if err == nil {
+		r.stats.observe(res.StatusCode, time.Since(start))
+		res.Body = &receivedBody{ReadCloser: res.Body, n: &r.stats.bytesReceived}
+	}
+	trace := t.result()
+	r.stats.observeTrace(trace, err == nil)
+	if r.hooks.OnTrace != nil {
+		r.hooks.OnTrace(req, trace)
+	}
+	if r.hooks.AfterResponse != nil {
+		r.hooks.AfterResponse(req, res, err)
+	}
+	return res, err
+}
+
+func (r *HttpReadSeeker) checkResponse(res *http.Response, off int64) (io.ReadCloser, error) {
 	switch res.StatusCode {
 	case http.StatusRequestedRangeNotSatisfiable:
-		return ErrInvalidRange
+		res.Body.Close()
+		if _, _, total, err := parseContentRange(res.Header.Get("Content-Range")); err == nil {
+			r.learnSize(total)
+		}
+		return nil, ErrInvalidRange
 	case http.StatusOK:
-		// some servers return 200 OK for bytes=0-
-		if r.pos > 0 ||
-			(etag != "" && etag != res.Header.Get("ETag")) {
-			return ErrContentHasChanged
+		// some servers return 200 OK for bytes=0-, others when If-Range does not match
+		etag, v := r.res.Header.Get("ETag"), r.validator()
+		if off > 0 ||
+			(etag != "" && etag != res.Header.Get("ETag")) ||
+			(v != "" && v != r.validatorOf(res.Header)) {
+			res.Body.Close()
+			return nil, ErrContentHasChanged
 		}
-		fallthrough
+		r.learnSize(res.ContentLength)
+		return res.Body, nil
 	case http.StatusPartialContent:
-		r.r = res.Body
+		if err := r.checkContentRange(res, off); err != nil {
+			res.Body.Close()
+			return nil, err
+		}
+		return res.Body, nil
+	}
+	res.Body.Close()
+	return nil, ErrRangeRequestsNotSupported
+}
+
+// checkContentRange checks that a 206 response starts at off and has the expected total size.
+// If allowed, bytes sent before off are discarded.
+func (r *HttpReadSeeker) checkContentRange(res *http.Response, off int64) error {
+	first, _, total, err := parseContentRange(res.Header.Get("Content-Range"))
+	if err != nil || first < 0 {
+		return ErrContentRangeMismatch
+	}
+	if size, ok := r.knownSize(); ok && total >= 0 && total != size {
+		return ErrContentRangeMismatch
+	}
+	r.learnSize(total)
+	switch {
+	case first > off:
+		return ErrContentRangeMismatch
+	case first < off:
+		if !r.tolerateEarlierStart {
+			return ErrContentRangeMismatch
+		}
+		if _, err := io.CopyN(ioutil.Discard, res.Body, off-first); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sleep waits for d, or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
 		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return ErrRangeRequestsNotSupported
 }
@@ -1,6 +1,9 @@
 package httprs
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -8,6 +11,8 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -42,7 +47,7 @@ func (f *fakeResponseWriter) Response() *http.Response {
 }
 
 type fakeRoundTripper struct {
-	src                    *os.File
+	src                    io.ReadSeeker
 	downgradeZeroToNoRange bool
 }
 
@@ -66,18 +71,19 @@ func (f *fakeRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
 
 const SZ = 4096
 
+// newTestContent returns "0000" to "4095"
+func newTestContent() []byte {
+	var buf bytes.Buffer
+	for i := 0; i < SZ; i++ {
+		fmt.Fprintf(&buf, "%04d", i)
+	}
+	return buf.Bytes()
+}
+
 type RSFactory func() *HttpReadSeeker
 
 func newRSFactory(brokenServer bool) RSFactory {
 	return func() *HttpReadSeeker {
-		tmp, err := ioutil.TempFile(os.TempDir(), "httprs")
-		if err != nil {
-			return nil
-		}
-		for i := 0; i < SZ; i++ {
-			tmp.WriteString(fmt.Sprintf("%04d", i))
-		}
-
 		req, err := http.NewRequest("GET", "http://www.example.com", nil)
 		if err != nil {
 			return nil
@@ -89,7 +95,7 @@ func newRSFactory(brokenServer bool) RSFactory {
 			Request:       req,
 			ContentLength: SZ * 4,
 		}
-		return NewHttpReadSeeker(res, &http.Client{Transport: &fakeRoundTripper{src: tmp, downgradeZeroToNoRange: brokenServer}})
+		return NewHttpReadSeeker(res, &http.Client{Transport: &fakeRoundTripper{src: bytes.NewReader(newTestContent()), downgradeZeroToNoRange: brokenServer}})
 	}
 }
 
@@ -212,18 +218,18 @@ func testHttpReaderSeeker(t *testing.T, newRS RSFactory) {
 			So(r, ShouldNotBeNil)
 			defer r.Close()
 			buf := make([]byte, 4)
-			So(r.Requests, ShouldEqual, 0)
+			So(r.Stats().RangeRequests, ShouldEqual, 0)
 			io.ReadFull(r, buf)
-			So(r.Requests, ShouldEqual, 1)
+			So(r.Stats().RangeRequests, ShouldEqual, 1)
 			s, err := r.Seek(shortSeekBytes, os.SEEK_CUR)
-			So(r.Requests, ShouldEqual, 1)
+			So(r.Stats().RangeRequests, ShouldEqual, 1)
 			So(s, ShouldEqual, shortSeekBytes+4)
 			So(err, ShouldBeNil)
 			n, err := io.ReadFull(r, buf)
 			So(n, ShouldEqual, 4)
 			So(err, ShouldBeNil)
 			So(string(buf), ShouldEqual, "0257")
-			So(r.Requests, ShouldEqual, 1)
+			So(r.Stats().RangeRequests, ShouldEqual, 1)
 		})
 
 		Convey("Long seek should do a new request", func() {
@@ -231,18 +237,523 @@ func testHttpReaderSeeker(t *testing.T, newRS RSFactory) {
 			So(r, ShouldNotBeNil)
 			defer r.Close()
 			buf := make([]byte, 4)
-			So(r.Requests, ShouldEqual, 0)
+			So(r.Stats().RangeRequests, ShouldEqual, 0)
 			io.ReadFull(r, buf)
-			So(r.Requests, ShouldEqual, 1)
+			So(r.Stats().RangeRequests, ShouldEqual, 1)
 			s, err := r.Seek(shortSeekBytes+1, os.SEEK_CUR)
-			So(r.Requests, ShouldEqual, 1)
+			So(r.Stats().RangeRequests, ShouldEqual, 1)
 			So(s, ShouldEqual, shortSeekBytes+4+1)
 			So(err, ShouldBeNil)
 			n, err := io.ReadFull(r, buf)
 			So(n, ShouldEqual, 4)
 			So(err, ShouldBeNil)
 			So(string(buf), ShouldEqual, "2570")
-			So(r.Requests, ShouldEqual, 2)
+			So(r.Stats().RangeRequests, ShouldEqual, 2)
+		})
+	})
+}
+
+func TestReadAt(t *testing.T) {
+	Convey("Scenario: testing ReadAt", t, func() {
+		content := newTestContent()
+		server := httptest.NewServer(serveTestContent(content, map[string]int{}))
+		defer server.Close()
+
+		res, err := http.Get(server.URL)
+		So(err, ShouldBeNil)
+		r := NewHttpReadSeeker(res)
+		defer r.Close()
+
+		Convey("ReadAt does not move the read position", func() {
+			buf := make([]byte, 4)
+			_, err := io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			n, err := r.ReadAt(buf, 4*100)
+			So(n, ShouldEqual, 4)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "0100")
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "0001")
+		})
+
+		Convey("ReadAt returns io.EOF at the end of the file", func() {
+			buf := make([]byte, 8)
+			n, err := r.ReadAt(buf, SZ*4-4)
+			So(n, ShouldEqual, 4)
+			So(err, ShouldEqual, io.EOF)
+			So(string(buf[:4]), ShouldEqual, "4095")
+			n, err = r.ReadAt(buf, SZ*4)
+			So(n, ShouldEqual, 0)
+			So(err, ShouldEqual, io.EOF)
+		})
+
+		Convey("ReadAt can be called concurrently", func() {
+			var wg sync.WaitGroup
+			errs := make([]error, 64)
+			for i := range errs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					buf := make([]byte, 4)
+					idx := (i * 61) % SZ
+					if _, err := r.ReadAt(buf, int64(idx*4)); err != nil {
+						errs[i] = err
+					} else if string(buf) != fmt.Sprintf("%04d", idx) {
+						errs[i] = fmt.Errorf("read %q at %d", buf, idx)
+					}
+				}(i)
+			}
+			wg.Wait()
+			for _, err := range errs {
+				So(err, ShouldBeNil)
+			}
+			So(r.Stats().RangeRequests, ShouldEqual, len(errs))
+		})
+
+		Convey("ReadAt continues after a short partial response", func() {
+			// the server returns at most 100 bytes per response
+			short := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				first, last := int64(0), int64(len(content)-1)
+				fmt.Sscanf(req.Header.Get("Range"), "bytes=%d-%d", &first, &last)
+				if last > first+99 {
+					last = first + 99
+				}
+				w.Header().Set("Accept-Ranges", "bytes")
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, len(content)))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(content[first : last+1])
+			}))
+			defer short.Close()
+			res, err := http.Get(short.URL)
+			So(err, ShouldBeNil)
+			r := NewHttpReadSeeker(res)
+			defer r.Close()
+
+			buf := make([]byte, 400)
+			n, err := r.ReadAt(buf, 1000)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 400)
+			So(string(buf), ShouldEqual, string(content[1000:1400]))
+			So(r.Stats().RangeRequests, ShouldEqual, 4)
+
+			var w bytes.Buffer
+			_, err = r.WriteTo(&w)
+			So(err, ShouldBeNil)
+			So(w.String(), ShouldEqual, string(content))
+		})
+	})
+}
+
+// failingBody returns io.ErrUnexpectedEOF after n bytes
+type failingBody struct {
+	io.ReadCloser
+	n int
+}
+
+func (b *failingBody) Read(p []byte) (int, error) {
+	if b.n <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if len(p) > b.n {
+		p = p[:b.n]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.n -= n
+	return n, err
+}
+
+func TestResume(t *testing.T) {
+	Convey("Scenario: testing resume after connection drops", t, func() {
+		content := newTestContent()
+		methods := map[string]int{}
+		serve := serveTestContent(content, methods)
+		drops := 3
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("If-Range") != "" && req.URL.Query().Get("changed") != "" {
+				w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+				w.Write(content)
+				return
+			}
+			serve(w, req)
+		}))
+		defer server.Close()
+		client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			res, err := http.DefaultTransport.RoundTrip(req)
+			if err == nil && req.Method == "GET" && drops > 0 {
+				drops--
+				res.Body = &failingBody{ReadCloser: res.Body, n: 1000}
+			}
+			return res, err
+		})}
+		var attempts int
+		resume := RetryFunc(func(attempt int, res *http.Response, err error) (time.Duration, bool) {
+			attempts++
+			return 0, attempt < 2
+		})
+
+		Convey("Read resumes from the current position", func() {
+			r, err := Open(context.Background(), server.URL, WithClient(client), WithResumePolicy(resume))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			b, err := ioutil.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, string(content))
+			So(r.Stats().RangeRequests, ShouldEqual, 4)
+			So(attempts, ShouldEqual, 3)
+		})
+
+		Convey("Read fails without a resume policy", func() {
+			r, err := Open(context.Background(), server.URL, WithClient(client))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			_, err = ioutil.ReadAll(r)
+			So(err, ShouldEqual, io.ErrUnexpectedEOF)
+		})
+
+		Convey("Read fails when the content has changed", func() {
+			r, err := Open(context.Background(), server.URL+"?changed=1", WithClient(client), WithResumePolicy(resume))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			_, err = ioutil.ReadAll(r)
+			So(errors.Is(err, ErrContentHasChanged), ShouldBeTrue)
+		})
+	})
+}
+
+func TestRangeError(t *testing.T) {
+	Convey("Scenario: testing RangeError", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Range") != "" {
+				w.Header().Set("X-Reason", "expired")
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Write(make([]byte, 100))
+		}))
+		defer server.Close()
+
+		res, err := http.Get(server.URL)
+		So(err, ShouldBeNil)
+		r := NewHttpReadSeeker(res)
+		defer r.Close()
+
+		_, err = r.ReadAt(make([]byte, 10), 50)
+		So(errors.Is(err, ErrRangeRequestsNotSupported), ShouldBeTrue)
+		var rerr *RangeError
+		So(errors.As(err, &rerr), ShouldBeTrue)
+		So(rerr.Offset, ShouldEqual, 50)
+		So(rerr.Length, ShouldEqual, 10)
+		So(rerr.StatusCode, ShouldEqual, http.StatusForbidden)
+		So(rerr.Header.Get("X-Reason"), ShouldEqual, "expired")
+		So(err.Error(), ShouldEqual, "Range request bytes=50-59 failed with status 403: "+ErrRangeRequestsNotSupported.Error())
+	})
+}
+
+func TestSize(t *testing.T) {
+	Convey("Scenario: testing size discovery", t, func() {
+		content := newTestContent()
+		serve := serveTestContent(content, map[string]int{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Range") != "" {
+				serve(w, req)
+				return
+			}
+			// chunked response, without Content-Length
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Write(content[:100])
+			w.(http.Flusher).Flush()
+			w.Write(content[100:])
+		}))
+		defer server.Close()
+
+		res, err := http.Get(server.URL)
+		So(err, ShouldBeNil)
+		So(res.ContentLength, ShouldEqual, -1)
+		r := NewHttpReadSeeker(res)
+		defer r.Close()
+
+		Convey("Size does a range request", func() {
+			size, err := r.Size()
+			So(err, ShouldBeNil)
+			So(size, ShouldEqual, SZ*4)
+			So(r.Stats().RangeRequests, ShouldEqual, 1)
+		})
+
+		Convey("Size is learnt from previous range requests", func() {
+			_, err := r.ReadAt(make([]byte, 4), 40)
+			So(err, ShouldBeNil)
+			size, err := r.Size()
+			So(err, ShouldBeNil)
+			So(size, ShouldEqual, SZ*4)
+			So(r.Stats().RangeRequests, ShouldEqual, 1)
+		})
+
+		Convey("Seek from the end works", func() {
+			pos, err := r.Seek(-4, io.SeekEnd)
+			So(err, ShouldBeNil)
+			So(pos, ShouldEqual, SZ*4-4)
+			buf := make([]byte, 4)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "4095")
+		})
+	})
+}
+
+func TestPartialResponse(t *testing.T) {
+	Convey("Scenario: testing an initial 206 response", t, func() {
+		content := newTestContent()
+		server := httptest.NewServer(serveTestContent(content, map[string]int{}))
+		defer server.Close()
+
+		get := func(rng string, opts ...Option) *HttpReadSeeker {
+			req, err := http.NewRequest("GET", server.URL, nil)
+			So(err, ShouldBeNil)
+			req.Header.Set("Range", rng)
+			res, err := http.DefaultClient.Do(req)
+			So(err, ShouldBeNil)
+			So(res.StatusCode, ShouldEqual, http.StatusPartialContent)
+			r, err := NewHttpReadSeekerWithOptions(res, opts...)
+			So(err, ShouldBeNil)
+			return r
+		}
+
+		Convey("The reader starts at the beginning of the range", func() {
+			r := get("bytes=5000-")
+			defer r.Close()
+			pos, err := r.Seek(0, io.SeekCurrent)
+			So(err, ShouldBeNil)
+			So(pos, ShouldEqual, 5000)
+			buf := make([]byte, 4)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "1250")
+			So(r.Stats().RangeRequests, ShouldEqual, 0)
+
+			pos, err = r.Seek(-4, io.SeekEnd)
+			So(err, ShouldBeNil)
+			So(pos, ShouldEqual, SZ*4-4)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "4095")
+		})
+
+		Convey("Reading continues after the end of a bounded range", func() {
+			r := get("bytes=100-199")
+			defer r.Close()
+			b, err := ioutil.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, string(content[100:]))
+			So(r.Stats().RangeRequests, ShouldEqual, 1)
+		})
+
+		Convey("Range windows start at their minimum after a bounded range", func() {
+			r := get("bytes=0-99", WithRangeWindow(1000, 4000))
+			defer r.Close()
+			b, err := ioutil.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, string(content))
+			// bytes=100-1099, 1100-3099, 3100-7099, 7100-11099, 11100-15099 and 15100-19099
+			So(r.Stats().RangeRequests, ShouldEqual, 6)
+		})
+
+		Convey("An empty partial body is not requested again", func() {
+			var requests int64
+			empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				atomic.AddInt64(&requests, 1)
+				var first int64
+				fmt.Sscanf(req.Header.Get("Range"), "bytes=%d-", &first)
+				w.Header().Set("Accept-Ranges", "bytes")
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-999/1000", first))
+				w.Header().Set("Content-Length", "0")
+				w.WriteHeader(http.StatusPartialContent)
+			}))
+			defer empty.Close()
+			req, err := http.NewRequest("GET", empty.URL, nil)
+			So(err, ShouldBeNil)
+			req.Header.Set("Range", "bytes=100-")
+			res, err := http.DefaultClient.Do(req)
+			So(err, ShouldBeNil)
+			r := NewHttpReadSeeker(res)
+			defer r.Close()
+			_, err = ioutil.ReadAll(r)
+			So(err, ShouldEqual, io.ErrUnexpectedEOF)
+			So(atomic.LoadInt64(&requests), ShouldEqual, 1)
+		})
+	})
+}
+
+func TestRangeWindow(t *testing.T) {
+	Convey("Scenario: testing bounded range requests", t, func() {
+		content := newTestContent()
+		serve := serveTestContent(content, map[string]int{})
+		var ranges []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if rng := req.Header.Get("Range"); rng != "" {
+				ranges = append(ranges, rng)
+			}
+			serve(w, req)
+		}))
+		defer server.Close()
+
+		r, err := Open(context.Background(), server.URL, WithRangeWindow(1000, 4000))
+		So(err, ShouldBeNil)
+		defer r.Close()
+
+		Convey("Windows grow while reading sequentially", func() {
+			b, err := ioutil.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, string(content))
+			So(ranges, ShouldResemble, []string{
+				"bytes=0-999", "bytes=1000-2999", "bytes=3000-6999",
+				"bytes=7000-10999", "bytes=11000-14999", "bytes=15000-18999",
+			})
+		})
+
+		Convey("Windows are reset by seeks", func() {
+			buf := make([]byte, 1200)
+			_, err := io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			_, err = r.Seek(8000, io.SeekStart)
+			So(err, ShouldBeNil)
+			_, err = io.ReadFull(r, buf[:4])
+			So(err, ShouldBeNil)
+			So(string(buf[:4]), ShouldEqual, "2000")
+			So(ranges, ShouldResemble, []string{"bytes=0-999", "bytes=1000-2999", "bytes=8000-8999"})
+		})
+	})
+}
+
+func TestDrain(t *testing.T) {
+	Convey("Scenario: testing abandoned bodies", t, func() {
+		content := newTestContent()
+		server := httptest.NewServer(http.HandlerFunc(serveTestContent(content, map[string]int{})))
+		defer server.Close()
+
+		seekAway := func(opts ...Option) Stats {
+			r, err := Open(context.Background(), server.URL, append(opts, WithRangeWindow(1000, 4000))...)
+			So(err, ShouldBeNil)
+			defer r.Close()
+			buf := make([]byte, 1200)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			// 1800 bytes of bytes=1000-2999 are left
+			_, err = r.Seek(8000, io.SeekStart)
+			So(err, ShouldBeNil)
+			_, err = io.ReadFull(r, buf[:4])
+			So(err, ShouldBeNil)
+			So(string(buf[:4]), ShouldEqual, "2000")
+			return r.Stats()
+		}
+
+		Convey("Short remainders are drained", func() {
+			stats := seekAway(WithDrainLimit(2000))
+			So(stats.DrainedBodies, ShouldEqual, 1)
+			So(stats.DiscardedBodies, ShouldEqual, 0)
+		})
+
+		Convey("Long remainders are discarded", func() {
+			stats := seekAway(WithDrainLimit(1000))
+			So(stats.DrainedBodies, ShouldEqual, 0)
+			So(stats.DiscardedBodies, ShouldEqual, 1)
+		})
+
+		Convey("Bodies are discarded by default", func() {
+			stats := seekAway()
+			So(stats.DrainedBodies, ShouldEqual, 0)
+			So(stats.DiscardedBodies, ShouldEqual, 1)
+		})
+
+		Convey("Close drains the remainder", func() {
+			r, err := Open(context.Background(), server.URL, WithRangeWindow(1000, 4000), WithDrainLimit(2000))
+			So(err, ShouldBeNil)
+			_, err = io.ReadFull(r, make([]byte, 10))
+			So(err, ShouldBeNil)
+			So(r.Close(), ShouldBeNil)
+			So(r.Stats().DrainedBodies, ShouldEqual, 1)
+		})
+	})
+}
+
+func TestClone(t *testing.T) {
+	Convey("Scenario: testing clones", t, func() {
+		content := newTestContent()
+		server := httptest.NewServer(http.HandlerFunc(serveTestContent(content, map[string]int{})))
+		defer server.Close()
+
+		res, err := http.Get(server.URL)
+		So(err, ShouldBeNil)
+		r := NewHttpReadSeeker(res)
+		defer r.Close()
+		buf := make([]byte, 8)
+		_, err = io.ReadFull(r, buf)
+		So(err, ShouldBeNil)
+
+		Convey("A clone starts at the position of the reader", func() {
+			c, err := r.Clone()
+			So(err, ShouldBeNil)
+			defer c.Close()
+			_, err = io.ReadFull(c, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "00020003")
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "00020003")
+		})
+
+		Convey("Clones read in parallel", func() {
+			var wg sync.WaitGroup
+			errs := make([]error, 8)
+			got := make([][]byte, 8)
+			for i := range errs {
+				c, err := r.Clone()
+				So(err, ShouldBeNil)
+				wg.Add(1)
+				go func(i int, c *HttpReadSeeker) {
+					defer wg.Done()
+					defer c.Close()
+					off := int64(i) * 2000
+					if _, errs[i] = c.Seek(off, io.SeekStart); errs[i] != nil {
+						return
+					}
+					got[i] = make([]byte, 1000)
+					_, errs[i] = io.ReadFull(c, got[i])
+				}(i, c)
+			}
+			wg.Wait()
+			for i := range errs {
+				So(errs[i], ShouldBeNil)
+				So(string(got[i]), ShouldEqual, string(content[i*2000:i*2000+1000]))
+			}
+		})
+
+		Convey("Clones share what is known about the content and stats", func() {
+			c, err := r.Clone()
+			So(err, ShouldBeNil)
+			defer c.Close()
+			So(c.state, ShouldEqual, r.state)
+			So(c.stats, ShouldEqual, r.stats)
+			So(c.validator(), ShouldEqual, r.validator())
+		})
+
+		Convey("A clone uses its own context", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			c, err := r.CloneWithContext(ctx)
+			So(err, ShouldBeNil)
+			defer c.Close()
+			_, err = c.Read(buf)
+			So(errors.Is(err, context.Canceled), ShouldBeTrue)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Clones of readers which cannot seek fail", func() {
+			r.canSeek = false
+			_, err := r.Clone()
+			So(err, ShouldEqual, ErrRangeRequestsNotSupported)
 		})
 	})
 }
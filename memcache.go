@@ -0,0 +1,114 @@
+package httprs
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// A MemoryCache is a Cache keeping blocks in memory, evicting the least recently used
+// ones when its memory budget is exceeded.
+type MemoryCache struct {
+	hits      int64 // accessed atomically, kept first for 64-bit alignment
+	misses    int64
+	evictions int64
+
+	bs  int64
+	max int64
+
+	mu     sync.Mutex // protects the fields below
+	size   int64
+	lru    *list.List // of *memoryBlock, most recently used first
+	blocks map[BlockKey]*list.Element
+}
+
+type memoryBlock struct {
+	key  BlockKey
+	data []byte
+}
+
+// CacheStats are counters of a cache.
+type CacheStats struct {
+	// Hits is the number of blocks found in the cache
+	Hits int64
+	// Misses is the number of blocks not found in the cache
+	Misses int64
+	// Evictions is the number of blocks removed to free space
+	Evictions int64
+	// Bytes is the size of the cached blocks
+	Bytes int64
+	// Blocks is the number of cached blocks
+	Blocks int
+}
+
+var _ Cache = (*MemoryCache)(nil)
+
+// NewMemoryCache returns a MemoryCache storing blocks of blockSize bytes, using at most
+// maxBytes bytes of memory.
+func NewMemoryCache(blockSize, maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		bs:     blockSize,
+		max:    maxBytes,
+		lru:    list.New(),
+		blocks: make(map[BlockKey]*list.Element),
+	}
+}
+
+// BlockSize returns the size of cached blocks
+func (c *MemoryCache) BlockSize() int64 {
+	return c.bs
+}
+
+// Get returns the block for key, if cached
+func (c *MemoryCache) Get(key BlockKey) ([]byte, bool) {
+	c.mu.Lock()
+	e, ok := c.blocks[key]
+	if ok {
+		c.lru.MoveToFront(e)
+	}
+	c.mu.Unlock()
+	if !ok {
+		atomic.AddInt64(&c.misses, 1)
+		return nil, false
+	}
+	atomic.AddInt64(&c.hits, 1)
+	return e.Value.(*memoryBlock).data, true
+}
+
+// Put adds a block to the cache, evicting old blocks if needed
+func (c *MemoryCache) Put(key BlockKey, block []byte) {
+	if int64(len(block)) > c.max {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.blocks[key]; ok {
+		c.remove(e)
+	}
+	c.blocks[key] = c.lru.PushFront(&memoryBlock{key: key, data: block})
+	c.size += int64(len(block))
+	for c.size > c.max {
+		c.remove(c.lru.Back())
+		atomic.AddInt64(&c.evictions, 1)
+	}
+}
+
+func (c *MemoryCache) remove(e *list.Element) {
+	b := c.lru.Remove(e).(*memoryBlock)
+	delete(c.blocks, b.key)
+	c.size -= int64(len(b.data))
+}
+
+// Stats returns the counters of the cache
+func (c *MemoryCache) Stats() CacheStats {
+	c.mu.Lock()
+	size, blocks := c.size, c.lru.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Hits:      atomic.LoadInt64(&c.hits),
+		Misses:    atomic.LoadInt64(&c.misses),
+		Evictions: atomic.LoadInt64(&c.evictions),
+		Bytes:     size,
+		Blocks:    blocks,
+	}
+}
@@ -0,0 +1,64 @@
+package httprs
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMemoryCache(t *testing.T) {
+	Convey("Scenario: testing MemoryCache", t, func() {
+		key := func(i int64) BlockKey { return BlockKey{URL: "http://www.example.com", Index: i} }
+
+		Convey("Least recently used blocks are evicted", func() {
+			c := NewMemoryCache(4, 8)
+			c.Put(key(0), []byte("0000"))
+			c.Put(key(1), []byte("0001"))
+			_, ok := c.Get(key(0))
+			So(ok, ShouldBeTrue)
+			c.Put(key(2), []byte("0002"))
+			_, ok = c.Get(key(1))
+			So(ok, ShouldBeFalse)
+			b, ok := c.Get(key(0))
+			So(ok, ShouldBeTrue)
+			So(string(b), ShouldEqual, "0000")
+			So(c.Stats(), ShouldResemble, CacheStats{Hits: 2, Misses: 1, Evictions: 1, Bytes: 8, Blocks: 2})
+		})
+
+		Convey("Blocks larger than the budget are not cached", func() {
+			c := NewMemoryCache(16, 8)
+			c.Put(key(0), make([]byte, 16))
+			_, ok := c.Get(key(0))
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Readers share the cache", func() {
+			client := &http.Client{Transport: &fakeRoundTripper{src: bytes.NewReader(newTestContent())}}
+			c := NewMemoryCache(1024, 1024*1024)
+
+			var requests int64
+			for i := 0; i < 3; i++ {
+				r, err := NewHttpReadSeekerWithOptions(newTestResponse(nil), WithClient(client), WithCache(c))
+				So(err, ShouldBeNil)
+				_, err = r.Seek(-4, io.SeekEnd)
+				So(err, ShouldBeNil)
+				buf := make([]byte, 4)
+				_, err = io.ReadFull(r, buf)
+				So(err, ShouldBeNil)
+				So(string(buf), ShouldEqual, "4095")
+				buf = make([]byte, 8)
+				_, err = r.ReadAt(buf, 1020)
+				So(err, ShouldBeNil)
+				So(string(buf), ShouldEqual, "02550256")
+				requests += r.Stats().RangeRequests
+				r.Close()
+			}
+			So(requests, ShouldEqual, 3)
+			So(c.Stats().Misses, ShouldEqual, 3)
+			So(c.Stats().Hits, ShouldEqual, 6)
+		})
+	})
+}
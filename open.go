@@ -0,0 +1,99 @@
+package httprs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// Open returns a HttpReadSeeker for url, without downloading the body.
+//
+// A HEAD request is sent to learn the size, validators and range support of the
+// content. If the server does not answer it properly, a bytes=0-0 range request is
+// sent instead. The body is then fetched by range requests on Read.
+//
+// If the server does not support range requests, the body of the bytes=0-0 request
+// is read sequentially, as with NewHttpReadSeeker.
+func Open(ctx context.Context, url string, opts ...Option) (*HttpReadSeeker, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newHttpReadSeeker(req, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.head(); err == nil {
+		return r, nil
+	}
+	if err := r.probe(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// head sets the reader up from a HEAD request
+func (r *HttpReadSeeker) head() error {
+	req := r.newRequest(r.ctx)
+	req.Method = "HEAD"
+	res, err := r.send(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK ||
+		res.Header.Get("Accept-Ranges") != "bytes" ||
+		res.ContentLength < 0 {
+		return fmt.Errorf("HEAD %s: %s", r.req.URL, res.Status)
+	}
+	res.Body = nil
+	r.res = res
+	r.state.size = res.ContentLength
+	r.canSeek = true
+	return nil
+}
+
+// probe sets the reader up from a bytes=0-0 range request
+func (r *HttpReadSeeker) probe() error {
+	req := r.newRequest(r.ctx)
+	req.Header.Set("Range", "bytes=0-0")
+
+	atomic.AddInt64(&r.stats.rangeRequests, 1)
+
+	res, err := r.send(req)
+	if err != nil {
+		return newRangeError(0, 1, nil, err)
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+		// no range support, stream the whole body
+		r.res = res
+		r.abort = func() { res.Body.Close() }
+		r.r = r.wrapBody(res.Body)
+		r.canSeek = (res.Header.Get("Accept-Ranges") == "bytes")
+		r.state.size = res.ContentLength
+		return nil
+	case http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+		res.Body.Close()
+		_, _, total, err := parseContentRange(res.Header.Get("Content-Range"))
+		if err != nil {
+			return err
+		}
+		r.res = &http.Response{
+			Status:        res.Status,
+			StatusCode:    res.StatusCode,
+			Proto:         res.Proto,
+			ProtoMajor:    res.ProtoMajor,
+			ProtoMinor:    res.ProtoMinor,
+			Header:        res.Header,
+			ContentLength: total,
+			Request:       res.Request,
+		}
+		r.state.size = total
+		r.canSeek = true
+		return nil
+	}
+	res.Body.Close()
+	return newRangeError(0, 1, res, ErrRangeRequestsNotSupported)
+}
@@ -0,0 +1,107 @@
+package httprs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// serveTestContent serves content with range support, counting requests by method.
+// Requests may be concurrent.
+func serveTestContent(content []byte, methods map[string]int) http.HandlerFunc {
+	modtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	return func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		methods[req.Method]++
+		mu.Unlock()
+		http.ServeContent(w, req, "file", modtime, bytes.NewReader(content))
+	}
+}
+
+func TestOpen(t *testing.T) {
+	Convey("Scenario: testing Open", t, func() {
+		content := newTestContent()
+		ctx := context.Background()
+
+		Convey("When the server supports HEAD", func() {
+			methods := map[string]int{}
+			server := httptest.NewServer(serveTestContent(content, methods))
+			defer server.Close()
+
+			r, err := Open(ctx, server.URL)
+			So(err, ShouldBeNil)
+			defer r.Close()
+			So(methods, ShouldResemble, map[string]int{"HEAD": 1})
+
+			Convey("Seeking from the end only does one ranged GET", func() {
+				_, err := r.Seek(-4, io.SeekEnd)
+				So(err, ShouldBeNil)
+				buf := make([]byte, 4)
+				_, err = io.ReadFull(r, buf)
+				So(err, ShouldBeNil)
+				So(string(buf), ShouldEqual, "4095")
+				So(methods, ShouldResemble, map[string]int{"HEAD": 1, "GET": 1})
+			})
+		})
+
+		Convey("When the server does not allow HEAD", func() {
+			methods := map[string]int{}
+			serve := serveTestContent(content, methods)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.Method == "HEAD" {
+					methods["HEAD"]++
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+				serve(w, req)
+			}))
+			defer server.Close()
+
+			r, err := Open(ctx, server.URL)
+			So(err, ShouldBeNil)
+			defer r.Close()
+			So(methods, ShouldResemble, map[string]int{"HEAD": 1, "GET": 1})
+
+			_, err = r.Seek(-4, io.SeekEnd)
+			So(err, ShouldBeNil)
+			buf := make([]byte, 4)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "4095")
+		})
+
+		Convey("When the server does not support ranges", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Write(content)
+			}))
+			defer server.Close()
+
+			r, err := Open(ctx, server.URL)
+			So(err, ShouldBeNil)
+			defer r.Close()
+
+			buf := make([]byte, 4)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "0000")
+			_, err = r.Seek(0, io.SeekStart)
+			So(err, ShouldEqual, ErrRangeRequestsNotSupported)
+		})
+
+		Convey("When the server fails", func() {
+			server := httptest.NewServer(http.NotFoundHandler())
+			defer server.Close()
+
+			_, err := Open(ctx, server.URL)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,263 @@
+package httprs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// An Option configures a HttpReadSeeker created by NewHttpReadSeekerWithOptions.
+type Option func(*HttpReadSeeker) error
+
+// ValidatorPolicy selects the validator sent in the If-Range header of range requests.
+type ValidatorPolicy int
+
+const (
+	// ValidateAuto uses Last-Modified if the initial response had one, ETag otherwise
+	ValidateAuto ValidatorPolicy = iota
+	// ValidateETag only uses the ETag of the initial response
+	ValidateETag
+	// ValidateLastModified only uses the Last-Modified date of the initial response
+	ValidateLastModified
+	// ValidateNone does not send If-Range headers
+	ValidateNone
+)
+
+// A RetryPolicy decides whether a failed range request is sent again.
+type RetryPolicy interface {
+	// Retry is called after the attempt-th attempt (starting at 1) failed, with the
+	// response (which may be nil) and the error. It returns the delay before the next
+	// attempt, or false to give up.
+	Retry(attempt int, res *http.Response, err error) (time.Duration, bool)
+}
+
+// RetryFunc is an adapter to use an ordinary function as a RetryPolicy.
+type RetryFunc func(attempt int, res *http.Response, err error) (time.Duration, bool)
+
+// Retry calls f(attempt, res, err).
+func (f RetryFunc) Retry(attempt int, res *http.Response, err error) (time.Duration, bool) {
+	return f(attempt, res, err)
+}
+
+// Hooks are called by a HttpReadSeeker around each range request. Nil hooks are ignored.
+type Hooks struct {
+	// BeforeRequest is called before a range request is sent. Headers of req may be modified.
+	BeforeRequest func(req *http.Request)
+	// AfterResponse is called when a range request has been answered or has failed.
+	AfterResponse func(req *http.Request, res *http.Response, err error)
+	// OnStall is called when Read aborts a stalled response body (see WithStallPolicy), with
+	// the position the next range request starts from and an error wrapping ErrStalled.
+	OnStall func(pos int64, err error)
+	// OnTrace is called after each request, with the DNS, connection, TLS and first byte
+	// timings of net/http/httptrace. Traces are also aggregated in Stats().Trace.
+	OnTrace func(req *http.Request, t RequestTrace)
+}
+
+// WithClient sets the http.Client used for range requests. Defaults to http.DefaultClient.
+func WithClient(c *http.Client) Option {
+	return func(r *HttpReadSeeker) error {
+		if c == nil {
+			return errors.New("http.Client is nil")
+		}
+		r.c = c
+		return nil
+	}
+}
+
+// WithShortSeekBytes sets how many bytes a forward Seek may discard from the current
+// response body instead of doing a new range request. Defaults to 1024.
+//
+// It overrides WithAdaptiveShortSeek.
+func WithShortSeekBytes(n int64) Option {
+	return func(r *HttpReadSeeker) error {
+		if n < 0 {
+			return fmt.Errorf("Invalid short seek threshold %d", n)
+		}
+		r.shortSeek = n
+		r.shortSeekSet = true
+		return nil
+	}
+}
+
+// WithAdaptiveShortSeek makes the short seek threshold follow the network conditions : the
+// latency of range requests and the throughput of response bodies are measured, and forward
+// seeks discard up to the number of bytes that could be received while a new request is sent,
+// within [min, max].
+func WithAdaptiveShortSeek(min, max int64) Option {
+	return func(r *HttpReadSeeker) error {
+		if min < 0 || max < min {
+			return fmt.Errorf("Invalid adaptive short seek bounds [%d, %d]", min, max)
+		}
+		r.estimator = &seekEstimator{min: min, max: max}
+		return nil
+	}
+}
+
+// WithValidatorPolicy sets which validator of the initial response is sent with If-Range.
+func WithValidatorPolicy(p ValidatorPolicy) Option {
+	return func(r *HttpReadSeeker) error {
+		if p < ValidateAuto || p > ValidateNone {
+			return fmt.Errorf("Invalid validator policy %d", p)
+		}
+		r.validatorPolicy = p
+		return nil
+	}
+}
+
+// WithTolerateEarlierStart accepts partial responses starting before the requested offset,
+// the extra bytes being discarded. By default, such responses fail with ErrContentRangeMismatch.
+func WithTolerateEarlierStart(tolerate bool) Option {
+	return func(r *HttpReadSeeker) error {
+		r.tolerateEarlierStart = tolerate
+		return nil
+	}
+}
+
+// WithRetryPolicy sets the policy used when a range request fails with a network error or
+// a transient status (429, 500, 502, 503 or 504). By default, range requests are not retried.
+// See ExponentialBackoff and DefaultRetryPolicy.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(r *HttpReadSeeker) error {
+		r.retry = p
+		return nil
+	}
+}
+
+// WithResumePolicy makes Read reconnect when the response body fails before its end
+// (e.g. with io.ErrUnexpectedEOF or a network error), by doing a range request from the
+// current position. p is called with a nil response after each failure without any byte
+// read, and decides how many times and how often reconnections are tried.
+//
+// If-Range is still sent, so ErrContentHasChanged is returned if the content has changed.
+func WithResumePolicy(p RetryPolicy) Option {
+	return func(r *HttpReadSeeker) error {
+		r.resume = p
+		return nil
+	}
+}
+
+// WithConcurrency sets how many range requests WriteTo and WriteToAt send in parallel.
+// Defaults to 4.
+func WithConcurrency(n int) Option {
+	return func(r *HttpReadSeeker) error {
+		if n <= 0 {
+			return fmt.Errorf("Invalid concurrency %d", n)
+		}
+		r.concurrency = n
+		return nil
+	}
+}
+
+// WithChunkSize sets the size of the range requests sent by WriteTo and WriteToAt.
+// Defaults to 1MiB.
+func WithChunkSize(n int64) Option {
+	return func(r *HttpReadSeeker) error {
+		if n <= 0 {
+			return fmt.Errorf("Invalid chunk size %d", n)
+		}
+		r.chunkSize = n
+		return nil
+	}
+}
+
+// WithReadAhead makes Read prefetch up to n bytes of the response body from a background
+// goroutine, while the caller processes the previous bytes. The prefetch stops on Seek
+// and Close.
+func WithReadAhead(n int) Option {
+	return func(r *HttpReadSeeker) error {
+		if n < 0 {
+			return fmt.Errorf("Invalid read-ahead size %d", n)
+		}
+		r.readAhead = n
+		return nil
+	}
+}
+
+// WithRewindBuffer makes Read keep the last n bytes of the response body, so that seeking
+// back to them does not need a new range request. See Stats().RequestsSaved.
+func WithRewindBuffer(n int) Option {
+	return func(r *HttpReadSeeker) error {
+		if n < 0 {
+			return fmt.Errorf("Invalid rewind buffer size %d", n)
+		}
+		r.rewind = n
+		return nil
+	}
+}
+
+// WithRangeWindow makes Read send bounded range requests (bytes=pos-pos+window-1) instead of
+// requesting everything up to the end. The window starts at min bytes, and doubles up to max
+// while the content is read sequentially. Bodies are then read until their end, which lets
+// http.Transport reuse connections.
+func WithRangeWindow(min, max int64) Option {
+	return func(r *HttpReadSeeker) error {
+		if min <= 0 || max < min {
+			return fmt.Errorf("Invalid range window [%d, %d]", min, max)
+		}
+		r.windowMin, r.windowMax = min, max
+		return nil
+	}
+}
+
+// WithDrainLimit makes Seek and Close read the rest of the abandoned response body when at
+// most n bytes remain, instead of closing it right away, so that http.Transport can reuse
+// the connection. It is mostly useful with WithRangeWindow. See Stats().DrainedBodies.
+func WithDrainLimit(n int64) Option {
+	return func(r *HttpReadSeeker) error {
+		if n < 0 {
+			return fmt.Errorf("Invalid drain limit %d", n)
+		}
+		r.drainLimit = n
+		return nil
+	}
+}
+
+// WithCoalescing sets how ranges are merged by Plan, ReadRanges and ReadAtBatch : ranges
+// separated by at most maxGap bytes are read by the same range request, the bytes in between
+// being discarded, as long as the request does not exceed maxSize bytes. Larger ranges are
+// read by several requests of at most maxSize bytes. maxSize defaults to the chunk size when 0.
+// By default, only overlapping and adjacent ranges are merged.
+func WithCoalescing(maxGap, maxSize int64) Option {
+	return func(r *HttpReadSeeker) error {
+		if maxGap < 0 || maxSize < 0 {
+			return fmt.Errorf("Invalid coalescing limits %d, %d", maxGap, maxSize)
+		}
+		r.coalesceGap, r.coalesceMax = maxGap, maxSize
+		return nil
+	}
+}
+
+// WithStallPolicy makes Read abort response bodies which stall according to p, and
+// reconnect with a range request from the current position. This protects long downloads
+// from servers which stop sending bytes, without limiting their total duration as
+// http.Client.Timeout does. See Hooks.OnStall.
+func WithStallPolicy(p StallPolicy) Option {
+	return func(r *HttpReadSeeker) error {
+		if p.Idle < 0 || p.MinRate < 0 || p.MaxReconnects < 0 || (p.MinRate > 0 && p.Window <= 0) {
+			return fmt.Errorf("Invalid stall policy %+v", p)
+		}
+		r.stall = p
+		return nil
+	}
+}
+
+// WithCache makes Read go through c, one block at a time, instead of streaming the
+// response body.
+func WithCache(c Cache) Option {
+	return func(r *HttpReadSeeker) error {
+		if c != nil && c.BlockSize() <= 0 {
+			return fmt.Errorf("Invalid cache block size %d", c.BlockSize())
+		}
+		r.cache = c
+		return nil
+	}
+}
+
+// WithHooks sets the hooks called around range requests.
+func WithHooks(h Hooks) Option {
+	return func(r *HttpReadSeeker) error {
+		r.hooks = h
+		return nil
+	}
+}
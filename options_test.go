@@ -0,0 +1,153 @@
+package httprs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+type mapCache struct {
+	sync.Mutex
+	bs     int64
+	blocks map[BlockKey][]byte
+}
+
+func (c *mapCache) BlockSize() int64 { return c.bs }
+
+func (c *mapCache) Get(key BlockKey) ([]byte, bool) {
+	c.Lock()
+	defer c.Unlock()
+	b, ok := c.blocks[key]
+	return b, ok
+}
+
+func (c *mapCache) Put(key BlockKey, block []byte) {
+	c.Lock()
+	defer c.Unlock()
+	c.blocks[key] = block
+}
+
+// newTestResponse returns the initial response for the test file, without a body
+func newTestResponse(h http.Header) *http.Response {
+	req, _ := http.NewRequest("GET", "http://www.example.com", nil)
+	if h == nil {
+		h = http.Header{}
+	}
+	h.Set("Accept-Ranges", "bytes")
+	return &http.Response{Header: h, Request: req, ContentLength: SZ * 4}
+}
+
+func TestOptions(t *testing.T) {
+	Convey("Scenario: testing options", t, func() {
+		client := &http.Client{Transport: &fakeRoundTripper{src: bytes.NewReader(newTestContent())}}
+
+		Convey("Invalid options are rejected", func() {
+			_, err := NewHttpReadSeekerWithOptions(newTestResponse(nil), WithShortSeekBytes(-1))
+			So(err, ShouldNotBeNil)
+			_, err = NewHttpReadSeekerWithOptions(newTestResponse(nil), WithClient(nil))
+			So(err, ShouldNotBeNil)
+			_, err = NewHttpReadSeekerWithOptions(newTestResponse(nil), WithValidatorPolicy(ValidatorPolicy(42)))
+			So(err, ShouldNotBeNil)
+			_, err = NewHttpReadSeekerWithOptions(&http.Response{})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Short seek threshold is configurable", func() {
+			r, err := NewHttpReadSeekerWithOptions(newTestResponse(nil), WithClient(client), WithShortSeekBytes(4096))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			buf := make([]byte, 4)
+			io.ReadFull(r, buf)
+			_, err = r.Seek(4096, io.SeekCurrent)
+			So(err, ShouldBeNil)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "1025")
+			So(r.Stats().RangeRequests, ShouldEqual, 1)
+		})
+
+		Convey("Validator policy selects the If-Range header", func() {
+			h := http.Header{}
+			h.Set("ETag", `"abc"`)
+			h.Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			var ifRange string
+			hooks := Hooks{BeforeRequest: func(req *http.Request) { ifRange = req.Header.Get("If-Range") }}
+			policies := map[ValidatorPolicy]string{
+				ValidateAuto:         "Mon, 02 Jan 2006 15:04:05 GMT",
+				ValidateETag:         `"abc"`,
+				ValidateLastModified: "Mon, 02 Jan 2006 15:04:05 GMT",
+				ValidateNone:         "",
+			}
+			for p, expected := range policies {
+				r, err := NewHttpReadSeekerWithOptions(newTestResponse(h), WithClient(client), WithValidatorPolicy(p), WithHooks(hooks))
+				So(err, ShouldBeNil)
+				r.Seek(4, io.SeekStart)
+				r.Read(make([]byte, 4))
+				r.Close()
+				So(ifRange, ShouldEqual, expected)
+			}
+		})
+
+		Convey("Retry policy retries failed requests", func() {
+			failures := 2
+			flaky := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				if failures > 0 {
+					failures--
+					return nil, errors.New("connection reset")
+				}
+				return client.Transport.RoundTrip(req)
+			})}
+			var attempts []int
+			retry := RetryFunc(func(attempt int, res *http.Response, err error) (time.Duration, bool) {
+				attempts = append(attempts, attempt)
+				return time.Millisecond, attempt < 3
+			})
+			r, err := NewHttpReadSeekerWithOptions(newTestResponse(nil), WithClient(flaky), WithRetryPolicy(retry))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			r.Seek(8, io.SeekStart)
+			buf := make([]byte, 4)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "0002")
+			So(attempts, ShouldResemble, []int{1, 2})
+			So(r.Stats().RangeRequests, ShouldEqual, 3)
+		})
+
+		Convey("Reads go through the cache", func() {
+			cache := &mapCache{bs: 100, blocks: map[BlockKey][]byte{}}
+			r, err := NewHttpReadSeekerWithOptions(newTestResponse(nil), WithClient(client), WithCache(cache))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			buf := make([]byte, 8)
+			r.Seek(96, io.SeekStart)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "00240025")
+			So(r.Stats().RangeRequests, ShouldEqual, 2)
+			r.Seek(96, io.SeekStart)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "00240025")
+			So(r.Stats().RangeRequests, ShouldEqual, 2)
+
+			r.Seek(-2, io.SeekEnd)
+			n, err := io.ReadFull(r, buf)
+			So(n, ShouldEqual, 2)
+			So(err, ShouldEqual, io.ErrUnexpectedEOF)
+			So(string(buf[:2]), ShouldEqual, "95")
+		})
+	})
+}
@@ -0,0 +1,114 @@
+package httprs
+
+import (
+	"context"
+	"sort"
+)
+
+// A Span is a range request planned to read several ranges at once
+type Span struct {
+	Range
+	// Ranges are the indexes of the ranges overlapped by the span, by offset. A range larger
+	// than the size limit of requests overlaps several spans.
+	Ranges []int
+}
+
+// A BatchRead is one of the reads of ReadAtBatch : P is filled with the bytes at Off
+type BatchRead struct {
+	Off int64
+	P   []byte
+}
+
+// Plan returns the range requests needed to read ranges, sorted by offset. Ranges separated
+// by at most the gap set by WithCoalescing are merged, as long as merged requests do not
+// exceed its size limit. Overlapping and adjacent ranges are always merged, and requests
+// larger than the size limit are split.
+func (r *HttpReadSeeker) Plan(ranges []Range) []Span {
+	maxSize := r.coalesceMax
+	if maxSize == 0 {
+		maxSize = r.chunkSize
+	}
+	return planRanges(ranges, r.coalesceGap, maxSize)
+}
+
+func planRanges(ranges []Range, maxGap, maxSize int64) []Span {
+	idx := make([]int, len(ranges))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		return ranges[idx[a]].Off < ranges[idx[b]].Off
+	})
+
+	var merged []Span
+	for _, i := range idx {
+		rg := ranges[i]
+		if n := len(merged); n > 0 {
+			s := &merged[n-1]
+			end := s.Off + s.Len
+			if rg.Off+rg.Len > end {
+				end = rg.Off + rg.Len
+			}
+			// overlapping and adjacent ranges are always merged, the span is split below
+			if gap := rg.Off - (s.Off + s.Len); gap <= 0 || (gap <= maxGap && end-s.Off <= maxSize) {
+				s.Len = end - s.Off
+				s.Ranges = append(s.Ranges, i)
+				continue
+			}
+		}
+		merged = append(merged, Span{Range: rg, Ranges: []int{i}})
+	}
+
+	var spans []Span
+	for _, s := range merged {
+		for off := s.Off; off < s.Off+s.Len; off += maxSize {
+			piece := Span{Range: Range{Off: off, Len: s.Off + s.Len - off}}
+			if piece.Len > maxSize {
+				piece.Len = maxSize
+			}
+			for _, i := range s.Ranges {
+				if rg := ranges[i]; rg.Off < piece.Off+piece.Len && rg.Off+rg.Len > piece.Off {
+					piece.Ranges = append(piece.Ranges, i)
+				}
+			}
+			spans = append(spans, piece)
+		}
+	}
+	return spans
+}
+
+// ReadAtBatch fills the buffers of reads with the bytes at their offsets. The reads are
+// merged by Plan, and the spans are requested at once (see ReadRanges) or by concurrent range
+// requests, the bytes being copied back to each buffer.
+//
+// Reads must be within the content, otherwise ReadAtBatch fails, as ReadAt would. Some
+// buffers may have been filled when an error is returned.
+func (r *HttpReadSeeker) ReadAtBatch(ctx context.Context, reads []BatchRead) error {
+	rr := &rangeReads{
+		ranges: make([]Range, len(reads)),
+		bufs:   make([][]byte, len(reads)),
+		filled: make([]int64, len(reads)),
+	}
+	for i, rd := range reads {
+		rr.ranges[i] = Range{Off: rd.Off, Len: int64(len(rd.P))}
+		rr.bufs[i] = rd.P
+	}
+	return r.readRanges(ctx, rr)
+}
+
+// readSpans reads spans by concurrent range requests, and copies their bytes to the buffers
+// of the ranges they cover
+func (r *HttpReadSeeker) readSpans(ctx context.Context, rr *rangeReads, spans []Span) error {
+	cs := make([]chunk, len(spans))
+	byChunk := make(map[chunk]Span, len(spans))
+	for i, s := range spans {
+		cs[i] = chunk{off: s.Off, length: s.Len}
+		byChunk[cs[i]] = s
+	}
+	return r.forEachChunk(ctx, cs, func(c chunk, b []byte) error {
+		for _, i := range byChunk[c].Ranges {
+			rr.copyTo(i, c.off, b)
+		}
+		return nil
+	})
+}
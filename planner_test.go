@@ -0,0 +1,161 @@
+package httprs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPlan(t *testing.T) {
+	Convey("Scenario: testing the range planner", t, func() {
+		ranges := []Range{
+			{Off: 1000, Len: 10}, // 0
+			{Off: 0, Len: 10},    // 1
+			{Off: 10, Len: 10},   // 2, adjacent to 1
+			{Off: 15, Len: 10},   // 3, overlaps 2
+			{Off: 60, Len: 10},   // 4, 35 bytes after 3
+			{Off: 1020, Len: 90}, // 5, 10 bytes after 0
+		}
+
+		Convey("Only adjacent and overlapping ranges are merged by default", func() {
+			So(planRanges(ranges, 0, 1<<20), ShouldResemble, []Span{
+				{Range: Range{Off: 0, Len: 25}, Ranges: []int{1, 2, 3}},
+				{Range: Range{Off: 60, Len: 10}, Ranges: []int{4}},
+				{Range: Range{Off: 1000, Len: 10}, Ranges: []int{0}},
+				{Range: Range{Off: 1020, Len: 90}, Ranges: []int{5}},
+			})
+		})
+
+		Convey("Ranges separated by small gaps are merged", func() {
+			So(planRanges(ranges, 40, 1<<20), ShouldResemble, []Span{
+				{Range: Range{Off: 0, Len: 70}, Ranges: []int{1, 2, 3, 4}},
+				{Range: Range{Off: 1000, Len: 110}, Ranges: []int{0, 5}},
+			})
+		})
+
+		Convey("Merged requests are capped", func() {
+			So(planRanges(ranges, 40, 100), ShouldResemble, []Span{
+				{Range: Range{Off: 0, Len: 70}, Ranges: []int{1, 2, 3, 4}},
+				{Range: Range{Off: 1000, Len: 10}, Ranges: []int{0}},
+				{Range: Range{Off: 1020, Len: 90}, Ranges: []int{5}},
+			})
+		})
+
+		Convey("Requests larger than the cap are split", func() {
+			So(planRanges([]Range{{Off: 0, Len: 250}, {Off: 0, Len: 250}, {Off: 200, Len: 60}}, 0, 100), ShouldResemble, []Span{
+				{Range: Range{Off: 0, Len: 100}, Ranges: []int{0, 1}},
+				{Range: Range{Off: 100, Len: 100}, Ranges: []int{0, 1}},
+				{Range: Range{Off: 200, Len: 60}, Ranges: []int{0, 1, 2}},
+			})
+		})
+
+		Convey("An empty batch needs no request", func() {
+			So(planRanges(nil, 0, 100), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestReadAtBatch(t *testing.T) {
+	Convey("Scenario: testing batches of reads", t, func() {
+		content := newTestContent()
+		serve := serveTestContent(content, map[string]int{})
+		var (
+			mu     sync.Mutex
+			ranges []string
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if rng := req.Header.Get("Range"); rng != "" {
+				mu.Lock()
+				ranges = append(ranges, rng)
+				mu.Unlock()
+				// no multiple range support, spans are read separately
+				if strings.Contains(rng, ",") {
+					req.Header.Del("Range")
+				}
+			}
+			serve(w, req)
+		}))
+		defer server.Close()
+
+		ctx := context.Background()
+		reads := func() []BatchRead {
+			return []BatchRead{
+				{Off: 400, P: make([]byte, 4)},
+				{Off: 0, P: make([]byte, 4)},
+				{Off: 8, P: make([]byte, 8)},
+				{Off: 100, P: make([]byte, 0)},
+				{Off: 420, P: make([]byte, 4)},
+			}
+		}
+		check := func(rs []BatchRead) {
+			for _, rd := range rs {
+				So(string(rd.P), ShouldEqual, string(content[rd.Off:rd.Off+int64(len(rd.P))]))
+			}
+		}
+
+		Convey("Spans are read and scattered", func() {
+			r, err := Open(ctx, server.URL, WithCoalescing(16, 0))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			ranges = nil
+			rs := reads()
+			So(r.ReadAtBatch(ctx, rs), ShouldBeNil)
+			check(rs)
+			sort.Strings(ranges)
+			So(ranges, ShouldResemble, []string{"bytes=0-15", "bytes=0-15,400-423", "bytes=400-423"})
+		})
+
+		Convey("Without coalescing, each distant read is a span", func() {
+			r, err := Open(ctx, server.URL)
+			So(err, ShouldBeNil)
+			defer r.Close()
+			ranges = nil
+			rs := reads()
+			So(r.ReadAtBatch(ctx, rs), ShouldBeNil)
+			check(rs)
+			So(len(ranges), ShouldEqual, 5)
+		})
+
+		Convey("Reads larger than the size limit are split", func() {
+			r, err := Open(ctx, server.URL, WithCoalescing(0, 1000))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			ranges = nil
+			rs := []BatchRead{{Off: 100, P: make([]byte, 2500)}}
+			So(r.ReadAtBatch(ctx, rs), ShouldBeNil)
+			check(rs)
+			sort.Strings(ranges)
+			So(ranges, ShouldResemble, []string{
+				"bytes=100-1099", "bytes=100-1099,1100-2099,2100-2599", "bytes=1100-2099", "bytes=2100-2599",
+			})
+		})
+
+		Convey("The plan follows the options", func() {
+			r, err := Open(ctx, server.URL, WithCoalescing(16, 0), WithChunkSize(20))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			So(r.Plan([]Range{{Off: 0, Len: 4}, {Off: 8, Len: 8}, {Off: 20, Len: 4}}), ShouldResemble, []Span{
+				{Range: Range{Off: 0, Len: 16}, Ranges: []int{0, 1}},
+				{Range: Range{Off: 20, Len: 4}, Ranges: []int{2}},
+			})
+		})
+
+		Convey("Reads after the end fail", func() {
+			r, err := Open(ctx, server.URL)
+			So(err, ShouldBeNil)
+			defer r.Close()
+			So(r.ReadAtBatch(ctx, []BatchRead{{Off: 16380, P: make([]byte, 8)}}), ShouldNotBeNil)
+		})
+
+		Convey("Invalid coalescing limits fail", func() {
+			_, err := Open(ctx, server.URL, WithCoalescing(-1, 0))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
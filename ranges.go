@@ -0,0 +1,212 @@
+package httprs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// A Range is a range of bytes of the remote content
+type Range struct {
+	Off int64
+	Len int64
+}
+
+// rangeReads are ranges being read, and their buffers
+type rangeReads struct {
+	ranges []Range
+	bufs   [][]byte
+	filled []int64 // number of bytes received from the start of each range
+}
+
+// done returns true if the i-th range has been received
+func (rr *rangeReads) done(i int) bool {
+	return rr.filled[i] >= rr.ranges[i].Len
+}
+
+// header returns the Range header requesting all spans
+func (rr *rangeReads) header(spans []Span) string {
+	specs := make([]string, len(spans))
+	for i, s := range spans {
+		specs[i] = fmt.Sprintf("%d-%d", s.Off, s.Off+s.Len-1)
+	}
+	return "bytes=" + strings.Join(specs, ",")
+}
+
+// fill copies b, received at off, to the buffers of the ranges it overlaps. A range received
+// in several parts is only done if its parts are received in order.
+func (rr *rangeReads) fill(off int64, b []byte) {
+	for i := range rr.ranges {
+		if rr.done(i) {
+			continue
+		}
+		if from, to := rr.copyTo(i, off, b); from <= rr.filled[i] && to > rr.filled[i] {
+			rr.filled[i] = to
+		}
+	}
+}
+
+// copyTo copies the bytes of b, received at off, which belong to the i-th range, to its buffer.
+// It returns the part of the range which was copied, relative to its start.
+func (rr *rangeReads) copyTo(i int, off int64, b []byte) (from, to int64) {
+	rg := rr.ranges[i]
+	from, to = off-rg.Off, off+int64(len(b))-rg.Off
+	if from < 0 {
+		from = 0
+	}
+	if to > rg.Len {
+		to = rg.Len
+	}
+	if from >= to {
+		return 0, 0
+	}
+	copy(rr.bufs[i][from:to], b[rg.Off+from-off:])
+	return from, to
+}
+
+// ReadRanges reads several ranges of the content, and returns their bytes in the order of
+// ranges. The ranges are merged by Plan, and all spans are requested at once
+// (Range: bytes=a-b,c-d,...), the multipart/byteranges response being split. Spans missing
+// from the response are then read by concurrent range requests (see WithConcurrency), which
+// is also how they are read if the server does not support multiple ranges.
+//
+// Ranges must be within the content, otherwise ReadRanges fails, as ReadAt would.
+func (r *HttpReadSeeker) ReadRanges(ctx context.Context, ranges []Range) ([][]byte, error) {
+	rr := &rangeReads{
+		ranges: ranges,
+		bufs:   make([][]byte, len(ranges)),
+		filled: make([]int64, len(ranges)),
+	}
+	for i, rg := range ranges {
+		if rg.Len <= 0 {
+			return nil, fmt.Errorf("Invalid range %d+%d", rg.Off, rg.Len)
+		}
+		rr.bufs[i] = make([]byte, rg.Len)
+	}
+	if err := r.readRanges(ctx, rr); err != nil {
+		return nil, err
+	}
+	return rr.bufs, nil
+}
+
+// readRanges fills the buffers of the ranges, using a multiple range request if more than
+// one span is needed
+func (r *HttpReadSeeker) readRanges(ctx context.Context, rr *rangeReads) error {
+	if !r.canSeek {
+		return ErrRangeRequestsNotSupported
+	}
+	for _, rg := range rr.ranges {
+		if rg.Off < 0 {
+			return fmt.Errorf("Invalid offset %d", rg.Off)
+		}
+	}
+	spans := r.planMissing(rr)
+	r.state.mu.Lock()
+	multi := len(spans) > 1 && r.cache == nil && !r.state.noMultiRange
+	r.state.mu.Unlock()
+	if multi {
+		if err := r.fetchRanges(ctx, rr, spans); err != nil {
+			return err
+		}
+		spans = r.planMissing(rr)
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	return r.readSpans(ctx, rr, spans)
+}
+
+// planMissing plans the ranges which are not done yet
+func (r *HttpReadSeeker) planMissing(rr *rangeReads) []Span {
+	var (
+		todo   []int
+		ranges []Range
+	)
+	for i, rg := range rr.ranges {
+		if !rr.done(i) {
+			todo = append(todo, i)
+			ranges = append(ranges, rg)
+		}
+	}
+	spans := r.Plan(ranges)
+	for _, s := range spans {
+		for j, k := range s.Ranges {
+			s.Ranges[j] = todo[k]
+		}
+	}
+	return spans
+}
+
+// fetchRanges does a multiple range request for spans, and fills the ranges found in the response
+func (r *HttpReadSeeker) fetchRanges(ctx context.Context, rr *rangeReads, spans []Span) error {
+	last := spans[len(spans)-1]
+	off, length := spans[0].Off, last.Off+last.Len-spans[0].Off
+	res, err := r.roundTrip(ctx, rr.header(spans))
+	if err == nil {
+		switch res.StatusCode {
+		case http.StatusPartialContent:
+			err = r.scatter(res, rr, length)
+			res.Body.Close()
+		case http.StatusOK:
+			// multiple ranges are not supported, unless the content has changed
+			var body io.ReadCloser
+			if body, err = r.checkResponse(res, 0); err == nil {
+				body.Close()
+				r.state.mu.Lock()
+				r.state.noMultiRange = true
+				r.state.mu.Unlock()
+			}
+		default:
+			_, err = r.checkResponse(res, off)
+		}
+	}
+	if err != nil {
+		return newRangeError(off, length, res, err)
+	}
+	return nil
+}
+
+// scatter splits a 206 response to a multiple range request. The server may have sent a
+// single part, e.g. if it merged the ranges.
+func (r *HttpReadSeeker) scatter(res *http.Response, rr *rangeReads, span int64) error {
+	mediaType, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		return r.scatterPart(res.Header.Get("Content-Range"), res.Body, rr, span)
+	}
+	mr := multipart.NewReader(res.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := r.scatterPart(part.Header.Get("Content-Range"), part, rr, span); err != nil {
+			return err
+		}
+	}
+}
+
+// scatterPart reads a part of a 206 response, and fills the ranges it contains
+func (r *HttpReadSeeker) scatterPart(contentRange string, body io.Reader, rr *rangeReads, span int64) error {
+	first, last, total, err := parseContentRange(contentRange)
+	// parts larger than the requested span are not expected, and would be read in memory
+	if err != nil || first < 0 || last-first+1 > span {
+		return ErrContentRangeMismatch
+	}
+	if size, ok := r.knownSize(); ok && total >= 0 && total != size {
+		return ErrContentRangeMismatch
+	}
+	r.learnSize(total)
+	b := make([]byte, last-first+1)
+	if _, err := io.ReadFull(body, b); err != nil {
+		return err
+	}
+	rr.fill(first, b)
+	return nil
+}
@@ -0,0 +1,143 @@
+package httprs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReadRanges(t *testing.T) {
+	Convey("Scenario: testing multiple range reads", t, func() {
+		content := newTestContent()
+		serve := serveTestContent(content, map[string]int{})
+		var (
+			mu     sync.Mutex
+			ranges []string
+			mode   string
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			rng := req.Header.Get("Range")
+			if rng != "" {
+				mu.Lock()
+				ranges = append(ranges, rng)
+				mu.Unlock()
+			}
+			multi := strings.Contains(rng, ",")
+			switch {
+			case multi && mode == "ignore":
+				req.Header.Del("Range")
+			case multi && mode == "merge":
+				first, last := int64(-1), int64(-1)
+				for _, spec := range strings.Split(strings.TrimPrefix(rng, "bytes="), ",") {
+					var f, l int64
+					fmt.Sscanf(spec, "%d-%d", &f, &l)
+					if first < 0 || f < first {
+						first = f
+					}
+					if l > last {
+						last = l
+					}
+				}
+				req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", first, last))
+			case multi && mode == "drop":
+				// only the first range is sent
+				req.Header.Set("Range", rng[:strings.Index(rng, ",")])
+			}
+			serve(w, req)
+		}))
+		defer server.Close()
+
+		ctx := context.Background()
+		r, err := Open(ctx, server.URL)
+		So(err, ShouldBeNil)
+		defer r.Close()
+		ranges = nil
+
+		want := []Range{{Off: 8000, Len: 8}, {Off: 40, Len: 4}, {Off: 100, Len: 12}, {Off: 40, Len: 4}}
+		check := func(bufs [][]byte) {
+			So(len(bufs), ShouldEqual, len(want))
+			for i, rg := range want {
+				So(string(bufs[i]), ShouldEqual, string(content[rg.Off:rg.Off+rg.Len]))
+			}
+		}
+
+		Convey("Ranges are read by a single request", func() {
+			bufs, err := r.ReadRanges(ctx, want)
+			So(err, ShouldBeNil)
+			check(bufs)
+			So(ranges, ShouldResemble, []string{"bytes=40-43,100-111,8000-8007"})
+		})
+
+		Convey("A single range is read by a simple range request", func() {
+			bufs, err := r.ReadRanges(ctx, want[1:2])
+			So(err, ShouldBeNil)
+			So(string(bufs[0]), ShouldEqual, "0010")
+			So(ranges, ShouldResemble, []string{"bytes=40-43"})
+		})
+
+		Convey("Merged ranges are split", func() {
+			mode = "merge"
+			bufs, err := r.ReadRanges(ctx, want)
+			So(err, ShouldBeNil)
+			check(bufs)
+			So(len(ranges), ShouldEqual, 1)
+		})
+
+		Convey("Ranges larger than the size limit are read in several parts", func() {
+			r.coalesceMax = 1000
+			bufs, err := r.ReadRanges(ctx, []Range{{Off: 100, Len: 2500}, {Off: 40, Len: 4}})
+			So(err, ShouldBeNil)
+			So(string(bufs[0]), ShouldEqual, string(content[100:2600]))
+			So(string(bufs[1]), ShouldEqual, "0010")
+			So(ranges, ShouldResemble, []string{"bytes=40-43,100-1099,1100-2099,2100-2599"})
+		})
+
+		Convey("Missing ranges are read by separate requests", func() {
+			mode = "drop"
+			bufs, err := r.ReadRanges(ctx, want)
+			So(err, ShouldBeNil)
+			check(bufs)
+			So(len(ranges), ShouldEqual, 3)
+		})
+
+		Convey("Ranges are read separately when the server does not support multiple ranges", func() {
+			mode = "ignore"
+			bufs, err := r.ReadRanges(ctx, want)
+			So(err, ShouldBeNil)
+			check(bufs)
+			So(len(ranges), ShouldEqual, 4)
+
+			ranges = nil
+			bufs, err = r.ReadRanges(ctx, want)
+			So(err, ShouldBeNil)
+			check(bufs)
+			So(len(ranges), ShouldEqual, 3)
+		})
+
+		Convey("Ranges after the end fail", func() {
+			_, err := r.ReadRanges(ctx, []Range{{Off: 0, Len: 4}, {Off: 20000, Len: 4}})
+			So(err, ShouldNotBeNil)
+			_, err = r.ReadRanges(ctx, []Range{{Off: 20000, Len: 4}})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Invalid ranges fail", func() {
+			_, err := r.ReadRanges(ctx, []Range{{Off: 0, Len: 0}})
+			So(err, ShouldNotBeNil)
+			So(ranges, ShouldBeEmpty)
+		})
+
+		Convey("Changed content fails", func() {
+			r.res.Header.Set("Last-Modified", "Wed, 01 Jan 2019 00:00:00 GMT")
+			_, err := r.ReadRanges(ctx, want)
+			So(errors.Is(err, ErrContentHasChanged), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,75 @@
+package httprs
+
+import (
+	"io"
+	"sync"
+)
+
+const readAheadChunkSize = 32 * 1024
+
+// readAheadBody reads a response body from a background goroutine, keeping at most
+// size bytes ahead of the reader.
+type readAheadBody struct {
+	body   io.ReadCloser
+	chunks chan []byte
+	err    error // set before chunks is closed
+	cur    []byte
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newReadAheadBody(body io.ReadCloser, size int) *readAheadBody {
+	cs := readAheadChunkSize
+	if size < cs {
+		cs = size
+	}
+	b := &readAheadBody{
+		body:   body,
+		chunks: make(chan []byte, size/cs),
+		done:   make(chan struct{}),
+	}
+	go b.fill(cs)
+	return b
+}
+
+func (b *readAheadBody) fill(cs int) {
+	defer close(b.chunks)
+	for {
+		buf := make([]byte, cs)
+		n, err := b.body.Read(buf)
+		if n > 0 {
+			select {
+			case b.chunks <- buf[:n]:
+			case <-b.done:
+				return
+			}
+		}
+		if err != nil {
+			b.err = err
+			return
+		}
+	}
+}
+
+func (b *readAheadBody) Read(p []byte) (int, error) {
+	if len(b.cur) == 0 {
+		c, ok := <-b.chunks
+		if !ok {
+			return 0, b.err
+		}
+		b.cur = c
+	}
+	n := copy(p, b.cur)
+	b.cur = b.cur[n:]
+	return n, nil
+}
+
+// Close stops the background goroutine and closes the body
+func (b *readAheadBody) Close() error {
+	var err error
+	b.once.Do(func() {
+		close(b.done)
+		err = b.body.Close()
+	})
+	return err
+}
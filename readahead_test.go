@@ -0,0 +1,90 @@
+package httprs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// countingBody counts the bytes read from it
+type countingBody struct {
+	io.Reader
+	n      int64
+	closed int32
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	atomic.AddInt64(&b.n, int64(n))
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	atomic.StoreInt32(&b.closed, 1)
+	return nil
+}
+
+func TestReadAhead(t *testing.T) {
+	Convey("Scenario: testing read-ahead", t, func() {
+		content := newTestContent()
+
+		Convey("The body is read ahead, up to the buffer size", func() {
+			body := &countingBody{Reader: bytes.NewReader(content)}
+			b := newReadAheadBody(body, 4096)
+			defer b.Close()
+			deadline := time.Now().Add(time.Second)
+			for atomic.LoadInt64(&body.n) < 4096 && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			So(atomic.LoadInt64(&body.n), ShouldBeBetweenOrEqual, 4096, 4096*2)
+
+			buf := make([]byte, 4)
+			_, err := io.ReadFull(b, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "0000")
+			rest, err := ioutil.ReadAll(b)
+			So(err, ShouldBeNil)
+			So(string(rest), ShouldEqual, string(content[4:]))
+		})
+
+		Convey("Close stops the read-ahead", func() {
+			body := &countingBody{Reader: bytes.NewReader(content)}
+			b := newReadAheadBody(body, 1024)
+			So(b.Close(), ShouldBeNil)
+			So(atomic.LoadInt32(&body.closed), ShouldEqual, 1)
+			_, ok := <-b.chunks
+			for ok {
+				_, ok = <-b.chunks
+			}
+		})
+
+		Convey("Readers can seek while reading ahead", func() {
+			server := httptest.NewServer(serveTestContent(content, map[string]int{}))
+			defer server.Close()
+			r, err := Open(context.Background(), server.URL, WithReadAhead(64*1024))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			buf := make([]byte, 4)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "0000")
+			_, err = r.Seek(4*100, io.SeekCurrent)
+			So(err, ShouldBeNil)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "0101")
+			_, err = r.Seek(4*2000, io.SeekStart)
+			So(err, ShouldBeNil)
+			rest, err := ioutil.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(rest), ShouldEqual, string(content[4*2000:]))
+		})
+	})
+}
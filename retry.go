@@ -0,0 +1,94 @@
+package httprs
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultRetryPolicy is a reasonable RetryPolicy for range requests
+var DefaultRetryPolicy = ExponentialBackoff{
+	MaxAttempts: 4,
+	Initial:     100 * time.Millisecond,
+	Max:         10 * time.Second,
+	Jitter:      0.5,
+}
+
+// ExponentialBackoff is a RetryPolicy waiting Initial before the second attempt, then twice
+// as long before each following attempt, up to Max.
+//
+// A Retry-After header sent by the server is honoured instead, unless it asks to wait
+// more than Max, in which case the request is not retried.
+type ExponentialBackoff struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+	// Initial is the delay before the second attempt
+	Initial time.Duration
+	// Max is the maximum delay between two attempts, 0 meaning no maximum
+	Max time.Duration
+	// Jitter is the fraction of each delay (0 to 1) which is randomly removed, so that
+	// clients do not retry all at the same time
+	Jitter float64
+}
+
+var _ RetryPolicy = ExponentialBackoff{}
+
+// Retry implements RetryPolicy
+func (b ExponentialBackoff) Retry(attempt int, res *http.Response, err error) (time.Duration, bool) {
+	if attempt >= b.MaxAttempts {
+		return 0, false
+	}
+	if d, ok := retryAfter(res); ok {
+		if b.Max > 0 && d > b.Max {
+			return 0, false
+		}
+		return d, true
+	}
+	d := b.Initial
+	for i := 1; i < attempt && (b.Max <= 0 || d < b.Max); i++ {
+		d *= 2
+	}
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+	if b.Jitter > 0 {
+		d -= time.Duration(b.Jitter * rand.Float64() * float64(d))
+	}
+	return d, true
+}
+
+// retryAfter returns the delay asked by the Retry-After header of res, if any
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// isTransient returns true for statuses of requests which may succeed if sent again
+func isTransient(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package httprs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	Convey("Scenario: testing ExponentialBackoff", t, func() {
+		b := ExponentialBackoff{MaxAttempts: 5, Initial: time.Second, Max: 5 * time.Second}
+
+		Convey("Delays double up to Max", func() {
+			var delays []time.Duration
+			for attempt := 1; ; attempt++ {
+				d, ok := b.Retry(attempt, nil, nil)
+				if !ok {
+					break
+				}
+				delays = append(delays, d)
+			}
+			So(delays, ShouldResemble, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second})
+		})
+
+		Convey("Jitter shortens delays", func() {
+			b.Jitter = 0.5
+			for i := 0; i < 100; i++ {
+				d, ok := b.Retry(2, nil, nil)
+				So(ok, ShouldBeTrue)
+				So(d, ShouldBeBetweenOrEqual, time.Second, 2*time.Second)
+			}
+		})
+
+		Convey("Retry-After is honoured", func() {
+			res := &http.Response{Header: http.Header{"Retry-After": []string{"3"}}}
+			d, ok := b.Retry(1, res, ErrServerUnavailable)
+			So(ok, ShouldBeTrue)
+			So(d, ShouldEqual, 3*time.Second)
+
+			res.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+			_, ok = b.Retry(1, res, ErrServerUnavailable)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
+
+func TestRetry(t *testing.T) {
+	Convey("Scenario: testing retries of range requests", t, func() {
+		content := newTestContent()
+		serve := serveTestContent(content, map[string]int{})
+		failures := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Method == "GET" && failures > 0 {
+				failures--
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			serve(w, req)
+		}))
+		defer server.Close()
+
+		Convey("Transient errors are retried", func() {
+			failures = 2
+			r, err := Open(context.Background(), server.URL, WithRetryPolicy(DefaultRetryPolicy))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			r.Seek(4, io.SeekStart)
+			buf := make([]byte, 4)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "0001")
+			So(r.Stats().RangeRequests, ShouldEqual, 3)
+		})
+
+		Convey("Transient errors are not reported as missing range support", func() {
+			failures = 1
+			r, err := Open(context.Background(), server.URL)
+			So(err, ShouldBeNil)
+			defer r.Close()
+			_, err = r.Read(make([]byte, 4))
+			So(errors.Is(err, ErrServerUnavailable), ShouldBeTrue)
+		})
+	})
+}
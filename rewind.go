@@ -0,0 +1,54 @@
+package httprs
+
+import "io"
+
+// rewindBody keeps the last bytes read from a body, so that the reader can go back a
+// few bytes without a new range request.
+type rewindBody struct {
+	io.ReadCloser
+	size int
+	hist []byte // last bytes read, at most 2*size
+	back int    // bytes of hist to read again before reading the body
+}
+
+func newRewindBody(body io.ReadCloser, size int) *rewindBody {
+	return &rewindBody{ReadCloser: body, size: size, hist: make([]byte, 0, 2*size)}
+}
+
+func (b *rewindBody) Read(p []byte) (int, error) {
+	if b.back > 0 {
+		n := copy(p, b.hist[len(b.hist)-b.back:])
+		b.back -= n
+		return n, nil
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.keep(p[:n])
+	return n, err
+}
+
+// keep appends bytes to the history, only retaining the last size bytes
+func (b *rewindBody) keep(p []byte) {
+	if len(p) >= b.size {
+		b.hist = append(b.hist[:0], p[len(p)-b.size:]...)
+		return
+	}
+	if len(b.hist)+len(p) > cap(b.hist) {
+		n := copy(b.hist, b.hist[len(b.hist)+len(p)-b.size:])
+		b.hist = b.hist[:n]
+	}
+	b.hist = append(b.hist, p...)
+}
+
+// rewind moves back n bytes, if they are still retained
+func (b *rewindBody) rewind(n int64) bool {
+	if n > int64(len(b.hist)-b.back) {
+		return false
+	}
+	b.back += int(n)
+	return true
+}
+
+// buffered returns how many bytes can be read again without reading the body
+func (b *rewindBody) buffered() int64 {
+	return int64(b.back)
+}
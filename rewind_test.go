@@ -0,0 +1,67 @@
+package httprs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRewindBuffer(t *testing.T) {
+	Convey("Scenario: testing the rewind buffer", t, func() {
+		content := newTestContent()
+
+		Convey("The last bytes can be read again", func() {
+			b := newRewindBody(ioutil.NopCloser(bytes.NewReader(content)), 10)
+			buf := make([]byte, 7)
+			for i := 0; i < 5; i++ {
+				io.ReadFull(b, buf)
+			}
+			So(b.rewind(10), ShouldBeTrue)
+			So(b.rewind(100), ShouldBeFalse)
+			io.ReadFull(b, buf)
+			So(string(buf), ShouldEqual, string(content[25:32]))
+			rest := make([]byte, 8)
+			io.ReadFull(b, rest)
+			So(string(rest), ShouldEqual, string(content[32:40]))
+		})
+
+		Convey("Short backward seeks do not send requests", func() {
+			server := httptest.NewServer(serveTestContent(content, map[string]int{}))
+			defer server.Close()
+			r, err := Open(context.Background(), server.URL, WithRewindBuffer(1024))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			buf := make([]byte, 400)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+
+			_, err = r.Seek(-8, io.SeekCurrent)
+			So(err, ShouldBeNil)
+			buf = make([]byte, 8)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "00980099")
+
+			_, err = r.Seek(0, io.SeekStart)
+			So(err, ShouldBeNil)
+			_, err = r.Seek(8, io.SeekCurrent)
+			So(err, ShouldBeNil)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "00020003")
+			So(r.Stats().RangeRequests, ShouldEqual, 1)
+			So(r.Stats().RequestsSaved, ShouldEqual, 2)
+
+			_, err = r.Seek(-4*300, io.SeekEnd)
+			So(err, ShouldBeNil)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			So(r.Stats().RangeRequests, ShouldEqual, 2)
+		})
+	})
+}
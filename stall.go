@@ -0,0 +1,147 @@
+package httprs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const defaultStallReconnects = 3
+
+// ErrStalled is returned by Read when response bodies kept stalling, see WithStallPolicy
+var ErrStalled = errors.New("Response body stalled")
+
+// A StallPolicy detects response bodies which stopped sending bytes, or send them too slowly.
+// Only the time spent waiting for the body counts, not the time spent by the caller between
+// two Reads.
+type StallPolicy struct {
+	// Idle is how long a Read may wait without receiving any byte. 0 disables it.
+	Idle time.Duration
+	// MinRate is the minimal throughput, in bytes per second, measured over periods of
+	// Window. 0 disables it.
+	MinRate int64
+	Window  time.Duration
+	// MaxReconnects is how many times in a row a stalled body is replaced by a new range
+	// request before Read fails with ErrStalled. Defaults to 3.
+	MaxReconnects int
+}
+
+func (p StallPolicy) enabled() bool {
+	return p.Idle > 0 || p.MinRate > 0
+}
+
+func (p StallPolicy) maxReconnects() int {
+	if p.MaxReconnects > 0 {
+		return p.MaxReconnects
+	}
+	return defaultStallReconnects
+}
+
+// stallBody aborts a response body when it stalls, its Read then failing with an error
+// wrapping ErrStalled
+type stallBody struct {
+	io.ReadCloser
+	p     StallPolicy
+	abort func()
+
+	mu          sync.Mutex // protects the fields below
+	reading     bool
+	readStart   time.Time     // start of the current Read
+	windowStart time.Time     // start of the time counted in the current window
+	busy        time.Duration // time spent in Read during the current window
+	bytes       int64         // bytes received during the current window
+	stalled     error
+
+	done chan struct{}
+	once sync.Once
+}
+
+func newStallBody(body io.ReadCloser, p StallPolicy, abort func()) *stallBody {
+	b := &stallBody{
+		ReadCloser: body,
+		p:          p,
+		abort:      abort,
+		done:       make(chan struct{}),
+	}
+	go b.watch()
+	return b
+}
+
+// watch checks the body periodically, until it is closed or aborted
+func (b *stallBody) watch() {
+	period := b.p.Idle
+	if b.p.MinRate > 0 && (period == 0 || b.p.Window < period) {
+		period = b.p.Window
+	}
+	t := time.NewTicker(period/4 + time.Millisecond)
+	defer t.Stop()
+	for {
+		select {
+		case <-b.done:
+			return
+		case now := <-t.C:
+			if b.check(now) {
+				b.abort()
+				return
+			}
+		}
+	}
+}
+
+// check returns true if the body has stalled
+func (b *stallBody) check(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var waiting, elapsed time.Duration
+	if b.reading {
+		waiting = now.Sub(b.readStart)
+		elapsed = now.Sub(b.windowStart)
+	}
+	if b.p.Idle > 0 && waiting >= b.p.Idle {
+		b.stalled = fmt.Errorf("%w: no byte received for %s", ErrStalled, waiting.Round(time.Millisecond))
+		return true
+	}
+	busy := b.busy + elapsed
+	if b.p.MinRate > 0 && busy >= b.p.Window {
+		rate := float64(b.bytes) / busy.Seconds()
+		if rate < float64(b.p.MinRate) {
+			b.stalled = fmt.Errorf("%w: %.0f bytes/s received during %s", ErrStalled, rate, busy.Round(time.Millisecond))
+			return true
+		}
+		b.busy, b.bytes, b.windowStart = 0, 0, now
+	}
+	return false
+}
+
+func (b *stallBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	if b.stalled != nil {
+		err := b.stalled
+		b.mu.Unlock()
+		return 0, err
+	}
+	b.reading = true
+	b.readStart = time.Now()
+	b.windowStart = b.readStart
+	b.mu.Unlock()
+
+	n, err := b.ReadCloser.Read(p)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reading = false
+	b.busy += time.Since(b.windowStart)
+	b.bytes += int64(n)
+	if err != nil && b.stalled != nil {
+		// the error comes from the abort
+		err = b.stalled
+	}
+	return n, err
+}
+
+func (b *stallBody) Close() error {
+	b.once.Do(func() { close(b.done) })
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,127 @@
+package httprs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStallPolicy(t *testing.T) {
+	Convey("Scenario: testing stalled bodies", t, func() {
+		content := newTestContent()
+		serve := serveTestContent(content, map[string]int{})
+		var (
+			mu       sync.Mutex
+			requests int
+			stalls   int // number of range requests which stall
+			slow     bool
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			var first int64
+			_, err := fmt.Sscanf(req.Header.Get("Range"), "bytes=%d-", &first)
+			stall := err == nil && requests < stalls
+			// only the first stalling body sends bytes
+			end := first
+			if requests == 0 {
+				end += 100
+			}
+			if err == nil {
+				requests++
+			}
+			mu.Unlock()
+			if !stall {
+				serve(w, req)
+				return
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, len(content)-1, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.(http.Flusher).Flush()
+			for off := first; off < end; off += 10 {
+				w.Write(content[off : off+10])
+				w.(http.Flusher).Flush()
+				if slow {
+					time.Sleep(20 * time.Millisecond)
+				}
+			}
+			<-req.Context().Done()
+		}))
+		defer server.Close()
+
+		var (
+			stalledAt []int64
+			stallErrs []error
+		)
+		hooks := Hooks{OnStall: func(pos int64, err error) {
+			stalledAt = append(stalledAt, pos)
+			stallErrs = append(stallErrs, err)
+		}}
+		open := func(p StallPolicy) *HttpReadSeeker {
+			r, err := Open(context.Background(), server.URL, WithStallPolicy(p), WithHooks(hooks))
+			So(err, ShouldBeNil)
+			return r
+		}
+
+		Convey("An idle body is replaced", func() {
+			stalls = 1
+			r := open(StallPolicy{Idle: 50 * time.Millisecond})
+			defer r.Close()
+			b, err := ioutil.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, string(content))
+			So(stalledAt, ShouldResemble, []int64{100})
+			So(errors.Is(stallErrs[0], ErrStalled), ShouldBeTrue)
+			So(requests, ShouldEqual, 2)
+		})
+
+		Convey("A slow body is replaced", func() {
+			stalls, slow = 1, true
+			r := open(StallPolicy{MinRate: 10000, Window: 100 * time.Millisecond})
+			defer r.Close()
+			b, err := ioutil.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, string(content))
+			So(len(stalledAt), ShouldEqual, 1)
+			So(stalledAt[0], ShouldBeLessThanOrEqualTo, 100)
+		})
+
+		Convey("Read fails when bodies keep stalling", func() {
+			stalls = 100
+			r := open(StallPolicy{Idle: 20 * time.Millisecond, MaxReconnects: 2})
+			defer r.Close()
+			buf := make([]byte, 100)
+			_, err := io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			_, err = r.Read(buf)
+			So(errors.Is(err, ErrStalled), ShouldBeTrue)
+			So(stalledAt, ShouldResemble, []int64{100, 100, 100})
+			So(requests, ShouldEqual, 3)
+		})
+
+		Convey("Time spent by the caller is not counted", func() {
+			r := open(StallPolicy{Idle: 20 * time.Millisecond})
+			defer r.Close()
+			buf := make([]byte, 4)
+			for i := 0; i < 3; i++ {
+				_, err := io.ReadFull(r, buf)
+				So(err, ShouldBeNil)
+				time.Sleep(50 * time.Millisecond)
+			}
+			So(stalledAt, ShouldBeEmpty)
+		})
+
+		Convey("Invalid policies fail", func() {
+			_, err := Open(context.Background(), server.URL, WithStallPolicy(StallPolicy{MinRate: 10}))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
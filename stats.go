@@ -0,0 +1,141 @@
+package httprs
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencySamples is how many of the last request latencies are kept for percentiles
+const latencySamples = 1024
+
+// Stats are counters of a HttpReadSeeker and its clones
+type Stats struct {
+	// RangeRequests is the number of range requests sent, including retries
+	RangeRequests int64
+	// BytesReceived is the number of bytes read from response bodies
+	BytesReceived int64
+	// BytesDiscarded is the number of bytes read and dropped by short forward seeks
+	BytesDiscarded int64
+	// BytesFromCache is the number of bytes read from cached blocks, see WithCache
+	BytesFromCache int64
+	// Retries is the number of range requests sent again by the retry policy
+	Retries int64
+	// Reconnects is the number of response bodies replaced by a new range request after a
+	// failure (see WithResumePolicy) or a stall (see WithStallPolicy)
+	Reconnects int64
+	// StatusCodes counts the responses by status code
+	StatusCodes map[int]int64
+	// Latency is the time to receive the response headers, over the last 1024 responses
+	Latency LatencyStats
+	// Trace aggregates the traces of all requests, see Hooks.OnTrace
+	Trace TraceStats
+
+	// RequestsSaved is the number of backward seeks answered from the rewind buffer
+	// instead of a new range request
+	RequestsSaved int64
+	// DrainedBodies is the number of abandoned response bodies read to their end before
+	// being closed, so that their connection could be reused
+	DrainedBodies int64
+	// DiscardedBodies is the number of response bodies closed before their end, which
+	// closes their connection
+	DiscardedBodies int64
+}
+
+// LatencyStats are percentiles of request latencies
+type LatencyStats struct {
+	Count int64 // number of responses since the reader was created
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// stats are the counters, updated atomically
+type stats struct {
+	rangeRequests   int64
+	bytesReceived   int64
+	bytesDiscarded  int64
+	bytesFromCache  int64
+	retries         int64
+	reconnects      int64
+	requestsSaved   int64
+	drainedBodies   int64
+	discardedBodies int64
+
+	mu        sync.Mutex // protects the fields below
+	statuses  map[int]int64
+	latencies []time.Duration // ring of the last latencySamples latencies
+	responses int64
+	trace     TraceStats
+}
+
+// observe records a response
+func (s *stats) observe(status int, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.statuses == nil {
+		s.statuses = make(map[int]int64)
+	}
+	s.statuses[status]++
+	if len(s.latencies) < latencySamples {
+		s.latencies = append(s.latencies, latency)
+	} else {
+		s.latencies[s.responses%latencySamples] = latency
+	}
+	s.responses++
+}
+
+// latency returns the percentiles of the kept latencies
+func (s *stats) latency() LatencyStats {
+	s.mu.Lock()
+	l := append([]time.Duration(nil), s.latencies...)
+	count := s.responses
+	s.mu.Unlock()
+	if len(l) == 0 {
+		return LatencyStats{}
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+	at := func(p int) time.Duration {
+		return l[(len(l)-1)*p/100]
+	}
+	return LatencyStats{Count: count, P50: at(50), P90: at(90), P99: at(99), Max: l[len(l)-1]}
+}
+
+// receivedBody counts the bytes read from a response body
+type receivedBody struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (c *receivedBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
+}
+
+// Stats returns a snapshot of the counters of the reader, which are shared with its clones
+func (r *HttpReadSeeker) Stats() Stats {
+	s := Stats{
+		RangeRequests:   atomic.LoadInt64(&r.stats.rangeRequests),
+		BytesReceived:   atomic.LoadInt64(&r.stats.bytesReceived),
+		BytesDiscarded:  atomic.LoadInt64(&r.stats.bytesDiscarded),
+		BytesFromCache:  atomic.LoadInt64(&r.stats.bytesFromCache),
+		Retries:         atomic.LoadInt64(&r.stats.retries),
+		Reconnects:      atomic.LoadInt64(&r.stats.reconnects),
+		Latency:         r.stats.latency(),
+		RequestsSaved:   atomic.LoadInt64(&r.stats.requestsSaved),
+		DrainedBodies:   atomic.LoadInt64(&r.stats.drainedBodies),
+		DiscardedBodies: atomic.LoadInt64(&r.stats.discardedBodies),
+		StatusCodes:     make(map[int]int64),
+	}
+	r.stats.mu.Lock()
+	for code, n := range r.stats.statuses {
+		s.StatusCodes[code] = n
+	}
+	s.Trace = r.stats.trace
+	r.stats.mu.Unlock()
+	return s
+}
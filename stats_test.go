@@ -0,0 +1,130 @@
+package httprs
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStats(t *testing.T) {
+	Convey("Scenario: testing stats", t, func() {
+		content := newTestContent()
+		serve := serveTestContent(content, map[string]int{})
+		var (
+			mu          sync.Mutex
+			unavailable int // number of range requests answered with 503
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			fail := req.Header.Get("Range") != "" && unavailable > 0
+			if fail {
+				unavailable--
+			}
+			mu.Unlock()
+			if fail {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			serve(w, req)
+		}))
+		defer server.Close()
+		ctx := context.Background()
+
+		Convey("Requests, bytes and statuses are counted", func() {
+			r, err := Open(ctx, server.URL, WithShortSeekBytes(100))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			buf := make([]byte, 100)
+			_, err = io.ReadFull(r, buf)
+			So(err, ShouldBeNil)
+			_, err = r.Seek(50, io.SeekCurrent)
+			So(err, ShouldBeNil)
+			_, err = r.Seek(8000, io.SeekStart)
+			So(err, ShouldBeNil)
+			b, err := ioutil.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, string(content[8000:]))
+
+			stats := r.Stats()
+			So(stats.RangeRequests, ShouldEqual, 2)
+			So(stats.BytesDiscarded, ShouldEqual, 50)
+			So(stats.BytesReceived, ShouldBeGreaterThanOrEqualTo, 150+len(b))
+			So(stats.StatusCodes, ShouldResemble, map[int]int64{http.StatusOK: 1, http.StatusPartialContent: 2})
+			So(stats.Latency.Count, ShouldEqual, 3)
+			So(stats.Latency.Max, ShouldBeGreaterThan, 0)
+			So(stats.Latency.P50, ShouldBeLessThanOrEqualTo, stats.Latency.Max)
+		})
+
+		Convey("Retries are counted", func() {
+			unavailable = 2
+			retry := RetryFunc(func(attempt int, res *http.Response, err error) (time.Duration, bool) {
+				return 0, attempt < 3
+			})
+			r, err := Open(ctx, server.URL, WithRetryPolicy(retry))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			_, err = r.ReadAt(make([]byte, 4), 0)
+			So(err, ShouldBeNil)
+			stats := r.Stats()
+			So(stats.RangeRequests, ShouldEqual, 3)
+			So(stats.Retries, ShouldEqual, 2)
+			So(stats.StatusCodes[http.StatusServiceUnavailable], ShouldEqual, 2)
+		})
+
+		Convey("Bytes read from the cache are counted", func() {
+			r, err := Open(ctx, server.URL, WithCache(NewMemoryCache(1024, 1024*1024)))
+			So(err, ShouldBeNil)
+			defer r.Close()
+			buf := make([]byte, 100)
+			_, err = r.ReadAt(buf, 0)
+			So(err, ShouldBeNil)
+			So(r.Stats().BytesFromCache, ShouldEqual, 0)
+			_, err = r.ReadAt(buf, 100)
+			So(err, ShouldBeNil)
+			So(r.Stats().BytesFromCache, ShouldEqual, 100)
+			So(r.Stats().RangeRequests, ShouldEqual, 1)
+		})
+
+		Convey("Clones share the stats", func() {
+			r, err := Open(ctx, server.URL)
+			So(err, ShouldBeNil)
+			defer r.Close()
+			c, err := r.Clone()
+			So(err, ShouldBeNil)
+			defer c.Close()
+			_, err = c.ReadAt(make([]byte, 4), 0)
+			So(err, ShouldBeNil)
+			So(r.Stats().RangeRequests, ShouldEqual, 1)
+		})
+	})
+
+	Convey("Scenario: testing latency percentiles", t, func() {
+		s := &stats{}
+		So(s.latency(), ShouldResemble, LatencyStats{})
+		for i := 1; i <= 100; i++ {
+			s.observe(http.StatusOK, time.Duration(i)*time.Millisecond)
+		}
+		l := s.latency()
+		So(l.Count, ShouldEqual, 100)
+		So(l.P50, ShouldEqual, 50*time.Millisecond)
+		So(l.P90, ShouldEqual, 90*time.Millisecond)
+		So(l.P99, ShouldEqual, 99*time.Millisecond)
+		So(l.Max, ShouldEqual, 100*time.Millisecond)
+
+		Convey("Only the last latencies are kept", func() {
+			for i := 0; i < latencySamples; i++ {
+				s.observe(http.StatusOK, time.Millisecond)
+			}
+			l := s.latency()
+			So(l.Count, ShouldEqual, 100+latencySamples)
+			So(l.Max, ShouldEqual, time.Millisecond)
+		})
+	})
+}
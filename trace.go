@@ -0,0 +1,127 @@
+package httprs
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// A RequestTrace describes how a request was sent, as reported by net/http/httptrace.
+// Durations are 0 for steps which did not happen, e.g. when a connection was reused.
+type RequestTrace struct {
+	// Reused is true if the request was sent on a previously used connection
+	Reused bool
+	// DNS is the duration of the DNS lookup
+	DNS time.Duration
+	// Connect is the duration of the TCP connection
+	Connect time.Duration
+	// TLS is the duration of the TLS handshake
+	TLS time.Duration
+	// FirstByte is the time from the start of the request to the first byte of the response
+	FirstByte time.Duration
+}
+
+// TraceStats are the traces of all requests, aggregated
+type TraceStats struct {
+	ReusedConnections int64
+	NewConnections    int64
+	// DNS, Connect, TLS and FirstByte are the total durations of these steps
+	DNS       time.Duration
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration
+}
+
+// tracer fills a RequestTrace from httptrace callbacks, which may be called concurrently
+type tracer struct {
+	mu                     sync.Mutex
+	start                  time.Time
+	dnsStart, connectStart time.Time
+	tlsStart               time.Time
+	trace                  RequestTrace
+}
+
+func newTracer() *tracer {
+	return &tracer{start: time.Now()}
+}
+
+func (t *tracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.trace.Reused = info.Reused
+			t.mu.Unlock()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.trace.DNS = time.Since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			t.mu.Lock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.mu.Lock()
+			if err == nil {
+				t.trace.Connect = time.Since(t.connectStart)
+			}
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.trace.TLS = time.Since(t.tlsStart)
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.trace.FirstByte = time.Since(t.start)
+			t.mu.Unlock()
+		},
+	}
+}
+
+// result returns the trace of the request
+func (t *tracer) result() RequestTrace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trace
+}
+
+// traced returns req with a tracer
+func traced(req *http.Request) (*http.Request, *tracer) {
+	t := newTracer()
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), t.clientTrace())), t
+}
+
+// observeTrace aggregates the trace of a request. Connections are only counted for answered requests.
+func (s *stats) observeTrace(t RequestTrace, answered bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case !answered:
+	case t.Reused:
+		s.trace.ReusedConnections++
+	default:
+		s.trace.NewConnections++
+	}
+	s.trace.DNS += t.DNS
+	s.trace.Connect += t.Connect
+	s.trace.TLS += t.TLS
+	s.trace.FirstByte += t.FirstByte
+}
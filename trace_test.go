@@ -0,0 +1,62 @@
+package httprs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTrace(t *testing.T) {
+	Convey("Scenario: testing request traces", t, func() {
+		content := newTestContent()
+		server := httptest.NewTLSServer(http.HandlerFunc(serveTestContent(content, map[string]int{})))
+		defer server.Close()
+
+		var (
+			mu     sync.Mutex
+			traces []RequestTrace
+		)
+		hooks := Hooks{OnTrace: func(req *http.Request, t RequestTrace) {
+			mu.Lock()
+			traces = append(traces, t)
+			mu.Unlock()
+		}}
+		r, err := Open(context.Background(), server.URL, WithClient(server.Client()), WithHooks(hooks))
+		So(err, ShouldBeNil)
+		defer r.Close()
+
+		buf := make([]byte, 4)
+		for _, off := range []int64{0, 4000, 8000} {
+			_, err := r.ReadAt(buf, off)
+			So(err, ShouldBeNil)
+		}
+
+		Convey("Each request is traced", func() {
+			So(len(traces), ShouldEqual, 4)
+			So(traces[0].Reused, ShouldBeFalse)
+			So(traces[0].Connect, ShouldBeGreaterThan, 0)
+			So(traces[0].TLS, ShouldBeGreaterThan, 0)
+			for _, t := range traces[1:] {
+				So(t.Reused, ShouldBeTrue)
+				So(t.Connect, ShouldEqual, 0)
+				So(t.TLS, ShouldEqual, 0)
+			}
+			for _, t := range traces {
+				So(t.FirstByte, ShouldBeGreaterThan, 0)
+			}
+		})
+
+		Convey("Traces are aggregated in stats", func() {
+			stats := r.Stats().Trace
+			So(stats.NewConnections, ShouldEqual, 1)
+			So(stats.ReusedConnections, ShouldEqual, 3)
+			So(stats.Connect, ShouldEqual, traces[0].Connect)
+			So(stats.TLS, ShouldEqual, traces[0].TLS)
+			So(stats.FirstByte, ShouldBeGreaterThanOrEqualTo, traces[0].FirstByte)
+		})
+	})
+}
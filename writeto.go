@@ -0,0 +1,197 @@
+package httprs
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+var _ io.WriterTo = (*HttpReadSeeker)(nil)
+
+// A chunk is a range of the remote content
+type chunk struct {
+	off    int64
+	length int64
+}
+
+// chunks splits [from, to) in chunks of the configured size
+func (r *HttpReadSeeker) chunks(from, to int64) []chunk {
+	var cs []chunk
+	for off := from; off < to; off += r.chunkSize {
+		length := r.chunkSize
+		if off+length > to {
+			length = to - off
+		}
+		cs = append(cs, chunk{off: off, length: length})
+	}
+	return cs
+}
+
+// readChunk fills buf with the chunk. buf is reallocated if it is too small.
+func (r *HttpReadSeeker) readChunk(ctx context.Context, c chunk, buf []byte) ([]byte, error) {
+	if int64(cap(buf)) < c.length {
+		buf = make([]byte, c.length)
+	}
+	buf = buf[:c.length]
+	n, err := r.readAt(ctx, buf, c.off)
+	if n < len(buf) {
+		// the size was known, the content is shorter than expected
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// forEachChunk fetches chunks using concurrent range requests, and calls fn for each of them,
+// from the fetching goroutines. The slice passed to fn is reused once fn returns.
+// It stops at the first error.
+func (r *HttpReadSeeker) forEachChunk(ctx context.Context, cs []chunk, fn func(c chunk, b []byte) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	todo := make(chan chunk)
+	go func() {
+		defer close(todo)
+		for _, c := range cs {
+			select {
+			case todo <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for i := 0; i < r.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, r.chunkSize)
+			for c := range todo {
+				b, err := r.readChunk(ctx, c, buf)
+				if err == nil {
+					err = fn(c, b)
+				}
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr == nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+// remaining prepares a parallel download from the current position to the end of the
+// content. It returns false if the content cannot be fetched by range requests.
+func (r *HttpReadSeeker) remaining() (from, to int64, ok bool) {
+	if !r.canSeek {
+		return 0, 0, false
+	}
+	size, err := r.Size()
+	if err != nil {
+		return 0, 0, false
+	}
+	if r.r != nil {
+		r.abandon()
+	}
+	return r.pos, size, true
+}
+
+// WriteTo writes the content, from the current position to the end, to w. The content is
+// fetched by concurrent range requests (see WithConcurrency and WithChunkSize), but
+// written in order.
+//
+// When range requests are not supported, the response body is copied.
+func (r *HttpReadSeeker) WriteTo(w io.Writer) (int64, error) {
+	from, to, ok := r.remaining()
+	if !ok {
+		return io.Copy(w, struct{ io.Reader }{r})
+	}
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+
+	type result struct {
+		b   []byte
+		err error
+	}
+	// results are queued in order. A chunk is only fetched once a slot of sem is free, and
+	// its slot is freed when it is dequeued, so at most concurrency chunks are fetched ahead.
+	results := make(chan chan result, r.concurrency)
+	sem := make(chan struct{}, r.concurrency)
+	go func() {
+		defer close(results)
+		for _, c := range r.chunks(from, to) {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			ch := make(chan result, 1)
+			select {
+			case results <- ch:
+			case <-ctx.Done():
+				return
+			}
+			go func(c chunk) {
+				b, err := r.readChunk(ctx, c, make([]byte, c.length))
+				ch <- result{b: b, err: err}
+			}(c)
+		}
+	}()
+
+	var written int64
+	for ch := range results {
+		res := <-ch
+		<-sem
+		err := res.err
+		if err == nil {
+			var n int
+			n, err = w.Write(res.b)
+			written += int64(n)
+			r.pos += int64(n)
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, ctx.Err()
+}
+
+// WriteToAt writes the content, from the current position to the end, to w, each byte
+// being written at its offset in the remote content. The content is fetched by concurrent
+// range requests (see WithConcurrency and WithChunkSize), and written as soon as a
+// chunk is received, so w must support concurrent writes to distinct ranges, as os.File does.
+//
+// After a failure, some ranges may not have been written. The position is moved to the end
+// only if all chunks were written.
+func (r *HttpReadSeeker) WriteToAt(w io.WriterAt) (int64, error) {
+	from, to, ok := r.remaining()
+	if !ok {
+		return 0, ErrRangeRequestsNotSupported
+	}
+	var written int64
+	err := r.forEachChunk(r.ctx, r.chunks(from, to), func(c chunk, b []byte) error {
+		n, err := w.WriteAt(b, c.off)
+		atomic.AddInt64(&written, int64(n))
+		return err
+	})
+	if err == nil {
+		r.pos = to
+	}
+	return written, err
+}
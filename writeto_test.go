@@ -0,0 +1,98 @@
+package httprs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// concurrencyCounter measures the maximum number of concurrent requests. Requests are
+// delayed, so that they overlap.
+type concurrencyCounter struct {
+	sync.Mutex
+	current, max int
+}
+
+func (c *concurrencyCounter) wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c.Lock()
+		c.current++
+		if c.current > c.max {
+			c.max = c.current
+		}
+		c.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		h.ServeHTTP(w, req)
+		c.Lock()
+		c.current--
+		c.Unlock()
+	})
+}
+
+func TestWriteTo(t *testing.T) {
+	Convey("Scenario: testing WriteTo and WriteToAt", t, func() {
+		content := newTestContent()
+		counter := &concurrencyCounter{}
+		server := httptest.NewServer(counter.wrap(serveTestContent(content, map[string]int{})))
+		defer server.Close()
+
+		r, err := Open(context.Background(), server.URL, WithConcurrency(3), WithChunkSize(1000))
+		So(err, ShouldBeNil)
+		defer r.Close()
+		_, err = r.Seek(10, io.SeekStart)
+		So(err, ShouldBeNil)
+
+		Convey("WriteTo writes the rest of the content in order", func() {
+			var buf bytes.Buffer
+			n, err := io.Copy(&buf, r)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, len(content)-10)
+			So(buf.String(), ShouldEqual, string(content[10:]))
+			So(r.Stats().RangeRequests, ShouldEqual, 17)
+			So(counter.max, ShouldBeLessThanOrEqualTo, 3)
+			pos, _ := r.Seek(0, io.SeekCurrent)
+			So(pos, ShouldEqual, len(content))
+		})
+
+		Convey("WriteToAt writes chunks at their offsets", func() {
+			f, err := ioutil.TempFile("", "httprs")
+			So(err, ShouldBeNil)
+			defer os.Remove(f.Name())
+			defer f.Close()
+			n, err := r.WriteToAt(f)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, len(content)-10)
+			b, err := ioutil.ReadFile(f.Name())
+			So(err, ShouldBeNil)
+			So(string(b[10:]), ShouldEqual, string(content[10:]))
+			So(counter.max, ShouldBeLessThanOrEqualTo, 3)
+		})
+
+		Convey("WriteTo falls back to copying without range support", func() {
+			plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Write(content)
+			}))
+			defer plain.Close()
+			res, err := http.Get(plain.URL)
+			So(err, ShouldBeNil)
+			r := NewHttpReadSeeker(res)
+			defer r.Close()
+			var buf bytes.Buffer
+			_, err = r.WriteTo(&buf)
+			So(err, ShouldBeNil)
+			So(buf.String(), ShouldEqual, string(content))
+			_, err = r.WriteToAt(nil)
+			So(errors.Is(err, ErrRangeRequestsNotSupported), ShouldBeTrue)
+		})
+	})
+}